@@ -0,0 +1,179 @@
+package pl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	error_t   = reflect.TypeOf((*error)(nil)).Elem()
+	context_t = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// callable is a function whose signature is validated to be invoked by the executor.
+// If the first parameter of the function is a context.Context, the context of the execution
+// is given to it and the parameter is not counted as an argument.
+type callable struct {
+	fv reflect.Value
+	ft reflect.Type
+
+	takes_ctx      bool
+	num_fixed_args int
+
+	// no_spread denotes that the result is not spread even if it is a slice.
+	no_spread bool
+}
+
+func newCallable(fn any) (*callable, error) {
+	no_spread := false
+	if f, ok := fn.(noSpread); ok {
+		fn = f.fn
+		no_spread = true
+	}
+
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("expected a function but it was %T", fn)
+	}
+
+	ft := fv.Type()
+
+	// Check if the number of returned values is valid.
+	if n := ft.NumOut(); n > 2 || n == 0 {
+		return nil, fmt.Errorf("function have to return one or two values but %d values are returned", n)
+	} else if n == 2 && !ft.Out(1).Implements(error_t) {
+		return nil, fmt.Errorf("type of second return value of the function must be an error but it was %s", ft.Out(1).Name())
+	}
+
+	takes_ctx := ft.NumIn() > 0 && ft.In(0) == context_t
+
+	num_fixed_args := ft.NumIn()
+	if ft.IsVariadic() {
+		num_fixed_args--
+	}
+	if takes_ctx {
+		num_fixed_args--
+	}
+
+	return &callable{
+		fv: fv,
+		ft: ft,
+
+		takes_ctx:      takes_ctx,
+		num_fixed_args: num_fixed_args,
+
+		no_spread: no_spread,
+	}, nil
+}
+
+// checkArity checks if the function can take n arguments.
+// If exact is false, n is the minimum number of arguments that will be given.
+func (c *callable) checkArity(n int, exact bool) error {
+	if c.ft.IsVariadic() {
+		if exact && n < c.num_fixed_args {
+			return fmt.Errorf("%w: expected at least %d args but %d args are given", ErrArity, c.num_fixed_args, n)
+		}
+	} else if n > c.num_fixed_args {
+		return fmt.Errorf("%w: expected %d args but at least %d args are given", ErrArity, c.num_fixed_args, n)
+	} else if exact && n != c.num_fixed_args {
+		return fmt.Errorf("%w: expected %d args but %d args are given", ErrArity, c.num_fixed_args, n)
+	}
+
+	return nil
+}
+
+// paramType returns the type of i-th argument.
+// It must be called with valid index.
+func (c *callable) paramType(i int) reflect.Type {
+	offset := 0
+	if c.takes_ctx {
+		offset = 1
+	}
+	if i < c.num_fixed_args {
+		return c.ft.In(offset + i)
+	}
+
+	return c.ft.In(offset + c.num_fixed_args).Elem()
+}
+
+// takesThunk reports whether the function takes a Thunk at i-th argument or any argument after it if `after` is true.
+func (c *callable) takesThunk(i int, after bool) bool {
+	for ; i < c.num_fixed_args; i++ {
+		if isThunk(c.paramType(i)) {
+			return true
+		}
+		if !after {
+			return false
+		}
+	}
+
+	return c.ft.IsVariadic() && isThunk(c.paramType(i))
+}
+
+// convertArg converts i-th argument into the type of the parameter at that position.
+// The returned error is an *argError.
+func (c *callable) convertArg(i int, arg any, find converterFinder) (reflect.Value, error) {
+	t_in := c.paramType(i)
+	if isThunk(t_in) {
+		v := reflect.ValueOf(arg)
+		if !v.IsValid() || !isThunk(v.Type()) {
+			v = reflect.ValueOf(Thunk(func() ([]any, error) { return []any{arg}, nil }))
+		}
+
+		return v.Convert(t_in), nil
+	}
+	if arg == nil {
+		switch t_in.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t_in), nil
+		}
+
+		return reflect.Value{}, &argError{index: i, err: &conversionError{out: t_in, err: errors.New("type cannot be nil")}}
+	}
+
+	t_arg := reflect.TypeOf(arg)
+	if t_arg.AssignableTo(t_in) {
+		return reflect.ValueOf(arg), nil
+	}
+
+	conv, err := find(t_in, t_arg)
+	if err != nil {
+		return reflect.Value{}, &argError{index: i, err: &conversionError{out: t_in, in: t_arg, err: err}}
+	}
+
+	v, err := conv(reflect.ValueOf(arg))
+	if err != nil {
+		return reflect.Value{}, &argError{index: i, err: &conversionError{out: t_in, in: t_arg, err: err}}
+	}
+
+	return reflect.ValueOf(v), nil
+}
+
+func (c *callable) invoke(ctx context.Context, args []any, find converterFinder) (any, error) {
+	if err := c.checkArity(len(args), true); err != nil {
+		return nil, err
+	}
+
+	input_args := make([]reflect.Value, 0, len(args)+1)
+	if c.takes_ctx {
+		input_args = append(input_args, reflect.ValueOf(&ctx).Elem())
+	}
+	for i, arg := range args {
+		v, err := c.convertArg(i, arg, find)
+		if err != nil {
+			return nil, err
+		}
+
+		input_args = append(input_args, v)
+	}
+
+	rst := c.fv.Call(input_args)
+	if len(rst) == 1 || (len(rst) == 2 && rst[1].IsNil()) {
+		return rst[0].Interface(), nil
+	} else {
+		err := rst[1].Interface().(error)
+		return rst[0].Interface(), err
+	}
+}
@@ -0,0 +1,177 @@
+// Command pl evaluates a pipeline expression against a data document.
+//
+// The data document is referenced by `$` in the expression. It is read from
+// the file given by -data, or from the standard input if it is not a terminal.
+// Both JSON and YAML documents are accepted.
+//
+// Usage:
+//
+//	pl [flags] <expr>
+//	pl repl [flags]
+//
+// "pl repl" evaluates expressions interactively with a data document loaded once.
+//
+// Exit codes:
+//
+//	0  results are printed
+//	1  the execution failed
+//	2  invalid usage or the data cannot be read
+//	3  the expression is invalid, such as a syntax error or an undefined function
+//	4  the execution succeeded but there are no results
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lesomnus/pl"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	exitOK      = 0
+	exitRuntime = 1
+	exitUsage   = 2
+	exitParse   = 3
+	exitEmpty   = 4
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "repl" {
+		return runRepl(args[1:], stdin, stdout, stderr)
+	}
+
+	flags := flag.NewFlagSet("pl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: pl [flags] <expr>\n       pl repl [flags]\n")
+		flags.PrintDefaults()
+	}
+
+	var (
+		data_path = flags.String("data", "", "path to the JSON or YAML data document; \"-\" for the standard input")
+		format    = flags.String("format", "json", "format of the results: json, text, or go")
+	)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	printer, ok := printers[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "pl: unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	data, err := readData(*data_path, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "pl: data: %s\n", err)
+		return exitUsage
+	}
+
+	expr := flags.Arg(0)
+	p, err := pl.ParseString(expr)
+	if err != nil {
+		fmt.Fprintln(stderr, pl.FormatDiagnostic(expr, err))
+		return exitParse
+	}
+
+	// Undefined functions, wrong number of arguments, and constants that cannot be converted
+	// are errors in the expression, not in the execution.
+	prog, err := pl.NewExecutor().Compile(p)
+	if err != nil {
+		fmt.Fprintln(stderr, pl.FormatDiagnostic(expr, err))
+		return exitParse
+	}
+
+	rst, err := prog.Run(data)
+	if err != nil {
+		fmt.Fprintln(stderr, pl.FormatDiagnostic(expr, err))
+		return exitRuntime
+	}
+
+	if err := printer(stdout, rst); err != nil {
+		fmt.Fprintf(stderr, "pl: %s\n", err)
+		return exitRuntime
+	}
+	if len(rst) == 0 {
+		return exitEmpty
+	}
+
+	return exitOK
+}
+
+// readData reads the data document from the file at the path.
+// If the path is empty, it reads the standard input unless it is a terminal.
+func readData(path string, stdin io.Reader) (any, error) {
+	var r io.Reader
+	switch path {
+	case "":
+		if f, ok := stdin.(*os.File); ok {
+			if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice != 0 {
+				return nil, nil
+			}
+		}
+		r = stdin
+
+	case "-":
+		r = stdin
+
+	default:
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	return decodeData(r)
+}
+
+// decodeData decodes a JSON or YAML document.
+// JSON is decoded by the YAML decoder too so integers are kept as int.
+func decodeData(r io.Reader) (any, error) {
+	var data any
+	if err := yaml.NewDecoder(r).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+var printers = map[string]func(w io.Writer, vs []any) error{
+	"json": func(w io.Writer, vs []any) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(vs)
+	},
+	"text": func(w io.Writer, vs []any) error {
+		for _, v := range vs {
+			if _, err := fmt.Fprintln(w, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	"go": func(w io.Writer, vs []any) error {
+		for _, v := range vs {
+			if _, err := fmt.Fprintf(w, "%#v\n", v); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
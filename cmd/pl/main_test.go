@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	data := "tags: [v1.2, v1.10, latest]\nanswer: 42\n"
+
+	tcs := []struct {
+		desc   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{
+			desc:   "JSON output",
+			args:   []string{`(pass $.tags[0] $.answer)`},
+			code:   exitOK,
+			stdout: "[\n  \"v1.2\",\n  42\n]\n",
+		},
+		{
+			desc:   "text output",
+			args:   []string{"-format", "text", `(regex "^v(\\d+)\\.(\\d+)$" $.tags[0] $.tags[1] $.tags[2] | pass "x")`},
+			code:   exitOK,
+			stdout: "x\nv1.2\nv1.10\n",
+		},
+		{
+			desc:   "Go output",
+			args:   []string{"-format=go", `(pass $.answer "x")`},
+			code:   exitOK,
+			stdout: "42\n\"x\"\n",
+		},
+		{
+			desc:   "empty result",
+			args:   []string{`(regex "foo" "bar")`},
+			code:   exitEmpty,
+			stdout: "[]\n",
+		},
+		{
+			desc:   "parse error",
+			args:   []string{`(pass &)`},
+			code:   exitParse,
+			stderr: "1:7: unexpected token",
+		},
+		{
+			desc:   "undefined function",
+			args:   []string{`(pass | rick)`},
+			code:   exitParse,
+			stderr: "1:9: fn[1] rick: function \"rick\" not defined",
+		},
+		{
+			desc:   "wrong number of arguments",
+			args:   []string{`(printf)`},
+			code:   exitParse,
+			stderr: "fn[0] printf: wrong number of arguments",
+		},
+		{
+			desc:   "runtime error",
+			args:   []string{`(pass $.rick)`},
+			code:   exitRuntime,
+			stderr: "1:7: fn[0] pass: arg[0]: reference: $ has no key rick",
+		},
+		{
+			desc:   "no expression",
+			args:   []string{},
+			code:   exitUsage,
+			stderr: "usage",
+		},
+		{
+			desc:   "unknown format",
+			args:   []string{"-format", "xml", `(pass)`},
+			code:   exitUsage,
+			stderr: "unknown format",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			code := run(append([]string{"-data", "-"}, tc.args...), strings.NewReader(data), stdout, stderr)
+			require.Equal(tc.code, code, stderr.String())
+			require.Equal(tc.stdout, stdout.String())
+			require.Contains(stderr.String(), tc.stderr)
+		})
+	}
+
+	t.Run("read data from a file", func(t *testing.T) {
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "data.json")
+		require.NoError(os.WriteFile(path, []byte(`{"a": {"b": [1, 2]}}`), 0644))
+
+		stdout := &bytes.Buffer{}
+		code := run([]string{"-data", path, "-format", "go", `(pass $.a.b[1])`}, nil, stdout, &bytes.Buffer{})
+		require.Equal(exitOK, code)
+		require.Equal("2\n", stdout.String())
+	})
+
+	t.Run("fails if data is invalid", func(t *testing.T) {
+		require := require.New(t)
+
+		stderr := &bytes.Buffer{}
+		code := run([]string{"-data", "-", `(pass)`}, strings.NewReader("a: [\n"), &bytes.Buffer{}, stderr)
+		require.Equal(exitUsage, code)
+		require.Contains(stderr.String(), "data")
+	})
+}
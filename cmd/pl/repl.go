@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lesomnus/pl"
+	"golang.org/x/term"
+)
+
+const replHelp = `Enter an expression to evaluate it. Parentheses around the pipeline can be omitted.
+Commands:
+  :funcs          list functions
+  :type <ref>     print the type of the referenced value, e.g. :type $.a.b
+  :load <file>    load a JSON or YAML data document
+  :trace          toggle printing of each function invocation
+  :help           print this message
+  :quit           exit
+`
+
+type repl struct {
+	executor *pl.Executor
+	printer  func(w io.Writer, vs []any) error
+
+	data  any
+	trace bool
+
+	out io.Writer
+}
+
+// runRepl runs "pl repl" that evaluates expressions read line by line.
+// The line is edited with history and completion if the standard input is a terminal.
+func runRepl(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("pl repl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: pl repl [flags]\n")
+		flags.PrintDefaults()
+	}
+
+	var (
+		data_path = flags.String("data", "", "path to the JSON or YAML data document")
+		format    = flags.String("format", "json", "format of the results: json, text, or go")
+	)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	// Start with an empty document so references fail rather than resolve against nothing.
+	r := &repl{executor: pl.NewExecutor(), data: map[string]any{}, out: stdout}
+	r.executor.Trace = r.printTrace
+
+	if printer, ok := printers[*format]; !ok {
+		fmt.Fprintf(stderr, "pl: unknown format %q\n", *format)
+		return exitUsage
+	} else {
+		r.printer = printer
+	}
+
+	if *data_path != "" {
+		if err := r.load(*data_path); err != nil {
+			fmt.Fprintf(stderr, "pl: data: %s\n", err)
+			return exitUsage
+		}
+	}
+
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		if err := r.interact(f, stdout); err != nil {
+			fmt.Fprintf(stderr, "pl: %s\n", err)
+			return exitRuntime
+		}
+		return exitOK
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		if !r.eval(scanner.Text()) {
+			break
+		}
+	}
+
+	return exitOK
+}
+
+func (r *repl) interact(stdin *os.File, stdout io.Writer) error {
+	fd := int(stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{stdin, stdout}, "pl> ")
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+
+		return r.complete(t, line, pos)
+	}
+
+	r.out = t
+	for {
+		line, err := t.ReadLine()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if !r.eval(line) {
+			return nil
+		}
+	}
+}
+
+// eval evaluates a line of input.
+// It returns false if the REPL should be terminated.
+func (r *repl) eval(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	if strings.HasPrefix(line, ":") {
+		return r.command(line)
+	}
+
+	expr := line
+	if !strings.HasPrefix(expr, "(") {
+		expr = "(" + expr + ")"
+	}
+
+	rst, err := r.executor.ExecuteExpr(expr, r.data)
+	if err != nil {
+		fmt.Fprintln(r.out, pl.FormatDiagnostic(expr, err))
+		return true
+	}
+
+	if err := r.printer(r.out, rst); err != nil {
+		fmt.Fprintln(r.out, err)
+	}
+
+	return true
+}
+
+func (r *repl) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":q", ":quit", ":exit":
+		return false
+
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+
+	case ":funcs":
+		names := r.funcNames()
+		for _, name := range names {
+			fmt.Fprintf(r.out, "%s %s\n", name, reflect.TypeOf(r.executor.Funcs[name]).String())
+		}
+
+	case ":type":
+		v, err := r.resolve(arg)
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+		} else {
+			fmt.Fprintf(r.out, "%T\n", v)
+		}
+
+	case ":load":
+		if err := r.load(arg); err != nil {
+			fmt.Fprintln(r.out, err)
+		}
+
+	case ":trace":
+		r.trace = !r.trace
+		if r.trace {
+			fmt.Fprintln(r.out, "trace on")
+		} else {
+			fmt.Fprintln(r.out, "trace off")
+		}
+
+	default:
+		fmt.Fprintf(r.out, "unknown command %s; see :help\n", name)
+	}
+
+	return true
+}
+
+func (r *repl) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := decodeData(f)
+	if err != nil {
+		return err
+	}
+
+	r.data = data
+	return nil
+}
+
+func (r *repl) printTrace(path []pl.Frame, args []any, rst any, err error) {
+	if !r.trace {
+		return
+	}
+
+	frame := path[len(path)-1]
+	indent := strings.Repeat("  ", len(path)-1)
+	if err != nil {
+		fmt.Fprintf(r.out, "%sfn[%d] %s %v -> error: %s\n", indent, frame.Fn, frame.Name, args, err)
+	} else {
+		fmt.Fprintf(r.out, "%sfn[%d] %s %v -> %v\n", indent, frame.Fn, frame.Name, args, rst)
+	}
+}
+
+// resolve resolves the reference written in the expression syntax.
+func (r *repl) resolve(ref string) (any, error) {
+	if ref == "$" {
+		return r.data, nil
+	}
+
+	p, err := pl.ParseString("(pass " + ref + ")")
+	if err != nil || len(p.Funcs[0].Args) != 1 {
+		return nil, fmt.Errorf("invalid reference %q", ref)
+	}
+
+	// Variables are not bound in the REPL so they refer to the fields of the data.
+	arg := p.Funcs[0].Args[0]
+	if arg.Var != nil {
+		return r.executor.Resolve(r.data, append(pl.Ref{{Name: arg.Var}}, arg.Ref...))
+	}
+	if arg.Ref == nil {
+		return nil, fmt.Errorf("invalid reference %q", ref)
+	}
+
+	return r.executor.Resolve(r.data, arg.Ref)
+}
+
+func (r *repl) funcNames() []string {
+	names := make([]string, 0, len(r.executor.Funcs))
+	for name := range r.executor.Funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// complete completes the word before `pos` in the line.
+// If there are multiple candidates, it completes their common prefix
+// or prints them if the prefix is already written.
+func (r *repl) complete(w io.Writer, line string, pos int) (string, int, bool) {
+	candidates, begin := r.candidates(line, pos)
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	word := line[begin:pos]
+	prefix := commonPrefix(candidates)
+	if len(candidates) > 1 && prefix == word {
+		fmt.Fprintln(w, strings.Join(candidates, "  "))
+		return "", 0, false
+	}
+
+	return line[:begin] + prefix + line[pos:], begin + len(prefix), true
+}
+
+// candidates returns words that can be placed at the word before `pos`
+// and the offset where the word begins.
+func (r *repl) candidates(line string, pos int) ([]string, int) {
+	begin := strings.LastIndexAny(line[:pos], " \t(|") + 1
+	word := line[begin:pos]
+
+	var candidates []string
+	switch {
+	case strings.HasPrefix(line, ":") && !strings.ContainsAny(line[:pos], " \t"):
+		candidates = []string{":funcs", ":help", ":load", ":quit", ":trace", ":type"}
+
+	case strings.HasPrefix(word, "$"):
+		candidates = r.refCandidates(word)
+
+	default:
+		// Function name is placed at the beginning of a pipeline or after a pipe.
+		before := strings.TrimRight(line[:begin], " \t")
+		if before == "" || strings.HasSuffix(before, "(") || strings.HasSuffix(before, "|") {
+			candidates = r.funcNames()
+		}
+	}
+
+	rst := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			rst = append(rst, candidate)
+		}
+	}
+
+	return rst, begin
+}
+
+// refCandidates returns references to the children of the value referenced
+// by the part of the word before the last key.
+func (r *repl) refCandidates(word string) []string {
+	base := word[:strings.LastIndexAny(word, ".[")+1]
+	if base == "" {
+		base = "$"
+	} else {
+		base = base[:len(base)-1]
+	}
+
+	v, err := r.resolve(base)
+	if err != nil {
+		return nil
+	}
+
+	cursor := reflect.ValueOf(v)
+	for cursor.Kind() == reflect.Pointer || cursor.Kind() == reflect.Interface {
+		cursor = cursor.Elem()
+	}
+
+	rst := []string{}
+	switch cursor.Kind() {
+	case reflect.Map:
+		if cursor.Type().Key().Kind() != reflect.String {
+			break
+		}
+
+		keys := []string{}
+		for _, key := range cursor.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			rst = append(rst, base+keyOf(key))
+		}
+
+	case reflect.Struct:
+		t := cursor.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				rst = append(rst, base+keyOf(t.Field(i).Name))
+			}
+		}
+
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < cursor.Len(); i++ {
+			rst = append(rst, fmt.Sprintf("%s[%d]", base, i))
+		}
+	}
+
+	return rst
+}
+
+func keyOf(name string) string {
+	if name == "" {
+		return `[""]`
+	}
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return "[" + strconv.Quote(name) + "]"
+		}
+	}
+
+	return "." + name
+}
+
+func commonPrefix(ss []string) string {
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lesomnus/pl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("a:\n  b: [1, 2]\n  c-d: foo\n"), 0644))
+
+	tcs := []struct {
+		desc     string
+		input    string
+		expected string
+	}{
+		{
+			desc:     "evaluate expressions",
+			input:    "(pass $.a.b[0])\nprintf \"%s\" $.a[\"c-d\"]\n",
+			expected: "[\n  1\n]\n[\n  \"foo\"\n]\n",
+		},
+		{
+			desc:     "print error",
+			input:    "(pass $.z)\n",
+			expected: "1:7: fn[0] pass: arg[0]: reference: $ has no key z: reference not found\n(pass $.z)\n      ^~~\n",
+		},
+		{
+			desc:     "print type",
+			input:    ":type $.a.b\n:type $\n:type $a.b[0]\n",
+			expected: "[]interface {}\nmap[string]interface {}\nint\n",
+		},
+		{
+			desc:     "trace",
+			input:    ":trace\npass 1 | pass\n:trace\npass 2\n",
+			expected: "trace on\nfn[0] pass [1] -> [1]\nfn[1] pass [1] -> [1]\n[\n  1\n]\ntrace off\n[\n  2\n]\n",
+		},
+		{
+			desc:     "quit",
+			input:    ":quit\npass 1\n",
+			expected: "",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			stdout := &bytes.Buffer{}
+			code := runRepl([]string{"-data", path}, strings.NewReader(tc.input), stdout, &bytes.Buffer{})
+			require.Equal(exitOK, code)
+			require.Equal(tc.expected, stdout.String())
+		})
+	}
+
+	t.Run("load data", func(t *testing.T) {
+		require := require.New(t)
+
+		stdout := &bytes.Buffer{}
+		code := runRepl(nil, strings.NewReader("pass $.a.b[1]\n:load "+path+"\npass $.a.b[1]\n"), stdout, &bytes.Buffer{})
+		require.Equal(exitOK, code)
+		require.Contains(stdout.String(), "1:7: fn[0] pass: arg[0]: reference")
+		require.True(strings.HasSuffix(stdout.String(), "[\n  2\n]\n"))
+	})
+}
+
+func TestReplComplete(t *testing.T) {
+	r := &repl{
+		executor: &pl.Executor{Funcs: pl.FuncMap{
+			"printf": nil,
+			"pass":   nil,
+			"regex":  nil,
+		}},
+		data: map[string]any{
+			"services": []any{
+				struct {
+					Name  string
+					Image string
+					inner int
+				}{},
+			},
+			"c-1": 1,
+			"sum": 2,
+		},
+	}
+
+	tcs := []struct {
+		desc       string
+		line       string
+		candidates []string
+		completed  string
+	}{
+		{
+			desc:       "function name",
+			line:       "(p",
+			candidates: []string{"pass", "printf"},
+			completed:  "(p",
+		},
+		{
+			desc:       "function name after pipe",
+			line:       "(pass 1 | r",
+			candidates: []string{"regex"},
+			completed:  "(pass 1 | regex",
+		},
+		{
+			desc:       "no function name at argument",
+			line:       "(pass p",
+			candidates: []string{},
+			completed:  "(pass p",
+		},
+		{
+			desc:       "keys of root",
+			line:       "(pass $",
+			candidates: []string{`$["c-1"]`, "$.services", "$.sum"},
+			completed:  "(pass $",
+		},
+		{
+			desc:       "key of root with prefix",
+			line:       "(pass $.se",
+			candidates: []string{"$.services"},
+			completed:  "(pass $.services",
+		},
+		{
+			desc:       "index",
+			line:       "pass $.services[",
+			candidates: []string{"$.services[0]"},
+			completed:  "pass $.services[0]",
+		},
+		{
+			desc:       "exported struct fields",
+			line:       "pass $.services[0].",
+			candidates: []string{"$.services[0].Name", "$.services[0].Image"},
+			completed:  "pass $.services[0].",
+		},
+		{
+			desc:       "command",
+			line:       ":t",
+			candidates: []string{":trace", ":type"},
+			completed:  ":t",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			candidates, _ := r.candidates(tc.line, len(tc.line))
+			require.Equal(tc.candidates, candidates)
+
+			w := &bytes.Buffer{}
+			line, _, ok := r.complete(w, tc.line, len(tc.line))
+			if !ok {
+				line = tc.line
+			}
+			require.Equal(tc.completed, line)
+			if len(candidates) > 1 {
+				require.Equal(strings.Join(candidates, "  ")+"\n", w.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diff returns the difference between a and b in unified format.
+func diff(name string, a []byte, b []byte) []byte {
+	lhs := splitLines(a)
+	rhs := splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of lhs[i:] and rhs[j:].
+	lcs := make([][]int, len(lhs)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(rhs)+1)
+	}
+	for i := len(lhs) - 1; i >= 0; i-- {
+		for j := len(rhs) - 1; j >= 0; j-- {
+			if lhs[i] == rhs[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type op struct {
+		kind byte
+		line string
+		i, j int
+	}
+
+	ops := []op{}
+	i, j := 0, 0
+	for i < len(lhs) || j < len(rhs) {
+		switch {
+		case i < len(lhs) && j < len(rhs) && lhs[i] == rhs[j]:
+			ops = append(ops, op{' ', lhs[i], i, j})
+			i++
+			j++
+		case j < len(rhs) && (i == len(lhs) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, op{'+', rhs[j], i, j})
+			j++
+		default:
+			ops = append(ops, op{'-', lhs[i], i, j})
+			i++
+		}
+	}
+
+	const context = 3
+
+	out := bytes.Buffer{}
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+
+		// Extend the hunk while changes are close enough.
+		begin := k - context
+		if begin < 0 {
+			begin = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		num_lhs, num_rhs := 0, 0
+		for _, o := range ops[begin:end] {
+			if o.kind != '+' {
+				num_lhs++
+			}
+			if o.kind != '-' {
+				num_rhs++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", ops[begin].i+1, num_lhs, ops[begin].j+1, num_rhs)
+		for _, o := range ops[begin:end] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		k = end
+	}
+
+	return out.Bytes()
+}
+
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/lesomnus/pl"
+	"gopkg.in/yaml.v3"
+)
+
+// formatExpr returns the canonical form of the expression.
+// Errors are prefixed by " line:column:" where the position is relative to the expression.
+func formatExpr(expr string) (string, error) {
+	p, err := pl.ParseString(expr)
+	if err != nil {
+		var parse_err participle.Error
+		if errors.As(err, &parse_err) {
+			pos := parse_err.Position()
+			return "", &posError{line: pos.Line, col: pos.Column, msg: parse_err.Message()}
+		}
+		return "", err
+	}
+
+	return p.String(), nil
+}
+
+type posError struct {
+	line int
+	col  int
+	msg  string
+}
+
+func (e *posError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.line, e.col, e.msg)
+}
+
+// formatLines formats each line as an expression.
+// Blank lines and lines start with "#" are kept as they are.
+func formatLines(src []byte) ([]byte, error) {
+	lines := strings.SplitAfter(string(src), "\n")
+	b := strings.Builder{}
+	for i, line := range lines {
+		content := strings.TrimRight(line, "\r\n")
+		eol := line[len(content):]
+
+		expr := strings.TrimLeft(content, " \t")
+		indent := content[:len(content)-len(expr)]
+		expr = strings.TrimRight(expr, " \t")
+		if expr == "" || strings.HasPrefix(expr, "#") {
+			b.WriteString(line)
+			continue
+		}
+
+		rst, err := formatExpr(expr)
+		if err != nil {
+			var pos_err *posError
+			if errors.As(err, &pos_err) {
+				pos_err.line = i + 1
+				pos_err.col += len(indent)
+			}
+			return nil, err
+		}
+
+		b.WriteString(indent)
+		b.WriteString(rst)
+		b.WriteString(eol)
+	}
+
+	return []byte(b.String()), nil
+}
+
+type edit struct {
+	begin int
+	end   int
+	text  string
+}
+
+// formatDocument formats expressions in string fields of YAML or JSON documents.
+// Only the expressions are rewritten so the rest of the document is kept as it is.
+// If `fields` is empty, every string that starts with "(" and is a valid expression is formatted.
+func formatDocument(src []byte, fields []string) ([]byte, error) {
+	nodes := []*yaml.Node{}
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		doc := &yaml.Node{}
+		if err := dec.Decode(doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf(" %w", err)
+		}
+
+		if len(fields) == 0 {
+			collectExprs(doc, &nodes)
+			continue
+		}
+		for _, field := range fields {
+			if err := selectFields(doc, strings.Split(field, "."), &nodes); err != nil {
+				return nil, fmt.Errorf(" %s: %w", field, err)
+			}
+		}
+	}
+
+	edits := []edit{}
+	for _, node := range nodes {
+		rst, err := formatExpr(node.Value)
+		if err != nil {
+			if len(fields) == 0 {
+				continue
+			}
+
+			var pos_err *posError
+			if errors.As(err, &pos_err) {
+				// Position in the expression is not exact in the document as it may be escaped.
+				return nil, fmt.Errorf("%d:%d: %s", node.Line, node.Column, pos_err.msg)
+			}
+			return nil, err
+		}
+		if rst == node.Value {
+			continue
+		}
+
+		begin := offsetOf(src, node.Line, node.Column)
+		text := rst
+		end, ok := 0, false
+		switch node.Style {
+		case yaml.LiteralStyle, yaml.FoldedStyle:
+			// The body is rewritten at its indentation so the header including chomping indicator is kept.
+			begin, end, ok = blockBody(src, begin)
+		default:
+			end, ok = scalarEnd(src, begin, node)
+			text = quoteAs(rst, node.Style)
+		}
+		if !ok {
+			if len(fields) == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%d:%d: unsupported style of string", node.Line, node.Column)
+		}
+
+		edits = append(edits, edit{begin: begin, end: end, text: text})
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].begin > edits[j].begin })
+
+	rst := append([]byte{}, src...)
+	for _, e := range edits {
+		rst = append(rst[:e.begin], append([]byte(e.text), rst[e.end:]...)...)
+	}
+
+	return rst, nil
+}
+
+func collectExprs(node *yaml.Node, nodes *[]*yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, n := range node.Content {
+			collectExprs(n, nodes)
+		}
+
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			collectExprs(node.Content[i], nodes)
+		}
+
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!str" && strings.HasPrefix(strings.TrimSpace(node.Value), "(") {
+			*nodes = append(*nodes, node)
+		}
+	}
+}
+
+func selectFields(node *yaml.Node, path []string, nodes *[]*yaml.Node) error {
+	if node.Kind == yaml.DocumentNode {
+		for _, n := range node.Content {
+			if err := selectFields(n, path, nodes); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(path) == 0 {
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!str" {
+			return fmt.Errorf("%d:%d: not a string", node.Line, node.Column)
+		}
+
+		*nodes = append(*nodes, node)
+		return nil
+	}
+
+	key := path[0]
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if key != "*" && node.Content[i].Value != key {
+				continue
+			}
+			if err := selectFields(node.Content[i+1], path[1:], nodes); err != nil {
+				return err
+			}
+		}
+
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			if key != "*" && key != strconv.Itoa(i) {
+				continue
+			}
+			if err := selectFields(n, path[1:], nodes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// offsetOf returns the byte offset of the position where the column counts characters.
+func offsetOf(src []byte, line int, col int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(src[offset:], '\n')
+		if i < 0 {
+			return len(src)
+		}
+		offset += i + 1
+	}
+	for ; col > 1 && offset < len(src); col-- {
+		_, n := utf8.DecodeRune(src[offset:])
+		offset += n
+	}
+
+	return offset
+}
+
+// scalarEnd returns the end offset of the scalar written in src from the offset `begin`.
+func scalarEnd(src []byte, begin int, node *yaml.Node) (int, bool) {
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := begin + 1; i < len(src); i++ {
+			switch src[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, true
+			}
+		}
+
+	case yaml.SingleQuotedStyle:
+		for i := begin + 1; i < len(src); i++ {
+			if src[i] != '\'' {
+				continue
+			}
+			if i+1 < len(src) && src[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+
+	case 0:
+		// Plain scalar in a single line is written as its value.
+		if bytes.HasPrefix(src[begin:], []byte(node.Value)) {
+			return begin + len(node.Value), true
+		}
+	}
+
+	return 0, false
+}
+
+// blockBody returns the offsets of the body of the block scalar whose header is at the offset `begin`.
+// The body spans from the first character of its first line that is not blank to the end of its last line that is not blank.
+func blockBody(src []byte, begin int) (int, int, bool) {
+	i := bytes.IndexByte(src[begin:], '\n')
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	body_begin, end := 0, 0
+	indent := -1
+	for offset := begin + i + 1; offset < len(src); {
+		line := src[offset:]
+		if j := bytes.IndexByte(line, '\n'); j >= 0 {
+			line = line[:j]
+		}
+		next := offset + len(line) + 1
+
+		content := bytes.TrimLeft(line, " ")
+		if len(bytes.TrimSpace(content)) == 0 {
+			offset = next
+			continue
+		}
+
+		n := len(line) - len(content)
+		if indent < 0 {
+			indent = n
+			body_begin = offset + n
+		} else if n < indent {
+			break
+		}
+
+		end = offset + len(bytes.TrimRight(line, "\r"))
+		offset = next
+	}
+	if indent <= 0 {
+		return 0, 0, false
+	}
+
+	return body_begin, end, true
+}
+
+// quoteAs writes the string in the given style.
+// It falls back to double-quoted style if the string cannot be written in the style.
+func quoteAs(s string, style yaml.Style) string {
+	switch style {
+	case yaml.SingleQuotedStyle:
+		if !strings.Contains(s, "\n") {
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		}
+
+	case 0:
+		if data, err := yaml.Marshal(s); err == nil {
+			plain := strings.TrimSuffix(string(data), "\n")
+			if plain == s && !strings.ContainsAny(s, ",[]{}") {
+				return s
+			}
+		}
+	}
+
+	// Double quoted string of JSON is also valid in YAML.
+	b := bytes.Buffer{}
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
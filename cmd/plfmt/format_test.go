@@ -0,0 +1,182 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatLines(t *testing.T) {
+	t.Run("format each line", func(t *testing.T) {
+		require := require.New(t)
+
+		src := "" +
+			"# comment\n" +
+			"(sum   1 2|sum 3)\n" +
+			"\n" +
+			"  (printf \"%s\"  $.a[\"b\"])  \r\n" +
+			"(pass)"
+
+		rst, err := formatLines([]byte(src))
+		require.NoError(err)
+		require.Equal(""+
+			"# comment\n"+
+			"(sum 1 2 | sum 3)\n"+
+			"\n"+
+			"  (printf \"%s\" $.a.b)\r\n"+
+			"(pass)", string(rst))
+	})
+
+	t.Run("fails with position of invalid expression", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := formatLines([]byte("(pass)\n  (pass & 1)\n"))
+		require.ErrorContains(err, "2:9: unexpected token")
+	})
+}
+
+func TestFormatDocument(t *testing.T) {
+	t.Run("YAML with fields", func(t *testing.T) {
+		require := require.New(t)
+
+		src := "" +
+			"# Rules\n" +
+			"rules:\n" +
+			"  - name: (not   an expression)\n" +
+			"    expr: (regex  \"v\" $.tags|pass)  # plain\n" +
+			"  - name: 한글\n" +
+			"    expr: '(printf  \"it''s\" 42)'\n" +
+			"  - expr: \"(pass\\t1)\"\n" +
+			"other: (pass   1)\n"
+
+		rst, err := formatDocument([]byte(src), []string{"rules.*.expr"})
+		require.NoError(err)
+		require.Equal(""+
+			"# Rules\n"+
+			"rules:\n"+
+			"  - name: (not   an expression)\n"+
+			"    expr: (regex \"v\" $.tags | pass)  # plain\n"+
+			"  - name: 한글\n"+
+			"    expr: '(printf \"it''s\" 42)'\n"+
+			"  - expr: \"(pass 1)\"\n"+
+			"other: (pass   1)\n", string(rst))
+	})
+
+	t.Run("YAML without fields", func(t *testing.T) {
+		require := require.New(t)
+
+		src := "" +
+			"a: (pass   1)\n" +
+			"b: (not an expression\n" +
+			"c: [(pass 1   2), foo]\n" +
+			"---\n" +
+			"d: (pass   \"x,y\")\n"
+
+		rst, err := formatDocument([]byte(src), nil)
+		require.NoError(err)
+		require.Equal(""+
+			"a: (pass 1)\n"+
+			"b: (not an expression\n"+
+			"c: [(pass 1 2), foo]\n"+
+			"---\n"+
+			"d: \"(pass \\\"x,y\\\")\"\n", string(rst))
+	})
+
+	t.Run("YAML with block scalars", func(t *testing.T) {
+		require := require.New(t)
+
+		src := "" +
+			"a: |\n" +
+			"  (pass   1)\n" +
+			"b: >-\n" +
+			"\n" +
+			"    (pass\n" +
+			"      2|pass)\n" +
+			"\n" +
+			"# comment\n" +
+			"c:\n" +
+			"  - |+\n" +
+			"    (pass   3)\n" +
+			"\n" +
+			"d: (pass\n" +
+			"  4)\n"
+
+		rst, err := formatDocument([]byte(src), nil)
+		require.NoError(err)
+		require.Equal(""+
+			"a: |\n"+
+			"  (pass 1)\n"+
+			"b: >-\n"+
+			"\n"+
+			"    (pass 2 | pass)\n"+
+			"\n"+
+			"# comment\n"+
+			"c:\n"+
+			"  - |+\n"+
+			"    (pass 3)\n"+
+			"\n"+
+			"d: (pass\n"+
+			"  4)\n", string(rst))
+
+		rst, err = formatDocument([]byte(src), []string{"c.0"})
+		require.NoError(err)
+		require.Contains(string(rst), "  - |+\n    (pass 3)\n\nd:")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		require := require.New(t)
+
+		src := `{
+	"z": "(pass   \"<\u00e9>\")",
+	"a": ["(pass 1)", "(pass 2|pass)"]
+}
+`
+		rst, err := formatDocument([]byte(src), []string{"z", "a.1"})
+		require.NoError(err)
+		require.Equal(`{
+	"z": "(pass \"<é>\")",
+	"a": ["(pass 1)", "(pass 2 | pass)"]
+}
+`, string(rst))
+	})
+
+	t.Run("fails if selected field is not a string", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := formatDocument([]byte("a:\n  b: 42\n"), []string{"a.b"})
+		require.ErrorContains(err, "not a string")
+	})
+
+	t.Run("fails if selected field is not an expression", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := formatDocument([]byte("a:\n  b: (pass &)\n"), []string{"a.b"})
+		require.ErrorContains(err, "2:6: unexpected token")
+	})
+}
+
+func TestDiff(t *testing.T) {
+	require := require.New(t)
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+
+	rst := diff("f", []byte(a), []byte(b))
+	require.Equal(""+
+		"--- f.orig\n"+
+		"+++ f\n"+
+		"@@ -1,6 +1,6 @@\n"+
+		" 1\n"+
+		" 2\n"+
+		"-3\n"+
+		"+three\n"+
+		" 4\n"+
+		" 5\n"+
+		" 6\n"+
+		"@@ -10,3 +10,4 @@\n"+
+		" 10\n"+
+		" 11\n"+
+		" 12\n"+
+		"+13\n"+
+		"\\ No newline at end of file\n", string(rst))
+}
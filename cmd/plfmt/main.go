@@ -0,0 +1,143 @@
+// Command plfmt formats pipeline expressions.
+//
+// Without file arguments, it reads expressions from the standard input.
+// Expressions are read one per line from plain files, or from string fields of
+// YAML and JSON documents. Fields are selected by -field paths, or every string
+// that starts with "(" is regarded as an expression if no path is given.
+//
+// Usage:
+//
+//	plfmt [flags] [path ...]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	list   = flag.Bool("l", false, "list files whose formatting differs from plfmt's")
+	write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
+	lang   = flag.String("type", "", "type of the input: lines, yaml, or json (default: detected from the file extension)")
+	fields fieldsFlag
+)
+
+type fieldsFlag []string
+
+func (f *fieldsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fieldsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: plfmt [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Var(&fields, "field", "dot separated path to the string field in YAML or JSON documents where `*` matches any key or index (repeatable)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "plfmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	exit_code := 0
+	for _, path := range flag.Args() {
+		if err := processPath(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit_code = 2
+		}
+	}
+
+	os.Exit(exit_code)
+}
+
+func processPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return processFile(path, f, os.Stdout)
+}
+
+func processFile(filename string, in io.Reader, out io.Writer) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	t := *lang
+	if t == "" {
+		t = typeOf(filename)
+	}
+
+	var rst []byte
+	switch t {
+	case "lines":
+		rst, err = formatLines(src)
+	case "yaml", "json":
+		rst, err = formatDocument(src, fields)
+	default:
+		return fmt.Errorf("%s: unknown type %q", filename, t)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", filename, err)
+	}
+
+	if !bytes.Equal(src, rst) {
+		if *list {
+			fmt.Fprintln(out, filename)
+		}
+		if *write {
+			info, err := os.Stat(filename)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(filename, rst, info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+		if *doDiff {
+			out.Write(diff(filename, src, rst))
+		}
+	}
+
+	if !*list && !*write && !*doDiff {
+		_, err = out.Write(rst)
+	}
+
+	return err
+}
+
+func typeOf(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	default:
+		return "lines"
+	}
+}
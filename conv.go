@@ -1,14 +1,34 @@
 package pl
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-var ErrNotFound = errors.New("not found")
+var (
+	ErrNotFound = errors.New("not found")
+	ErrLossy    = errors.New("conversion is lossy")
+)
+
+var (
+	bool_t     = reflect.TypeOf(false)
+	string_t   = reflect.TypeOf("")
+	stringer_t = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+	text_marshaler_t   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	text_unmarshaler_t = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	json_unmarshaler_t = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
 
-var string_t = reflect.TypeOf("")
+// maxConvHops is the maximum number of conversions chained by ConvMap.Find.
+const maxConvHops = 3
 
 type ConvMap map[reflect.Type]map[reflect.Type](func(v reflect.Value) (any, error))
 
@@ -52,26 +72,827 @@ func (m ConvMap) MergeWith(other ConvMap) {
 }
 
 func (m ConvMap) Convert(out reflect.Type, in reflect.Type, v reflect.Value) (any, error) {
-	convs, ok := m[in]
-	if !ok {
+	conv, err := m.Find(out, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return conv(v)
+}
+
+func (m ConvMap) ConvertTo(out reflect.Type, in any) (any, error) {
+	return m.Convert(out, reflect.TypeOf(in), reflect.ValueOf(in))
+}
+
+// defaultConversions converts among integers, floats, bool, and string.
+// Numbers are converted only if the value fits in the type;
+// floats must be integral to be integers, integers must be exact in floats,
+// float64s must keep their shortest decimal form in float32,
+// and only 0 and 1 are bools.
+var defaultConversions = func() ConvMap {
+	numbers := []reflect.Type{
+		reflect.TypeOf(int(0)),
+		reflect.TypeOf(int8(0)),
+		reflect.TypeOf(int16(0)),
+		reflect.TypeOf(int32(0)),
+		reflect.TypeOf(int64(0)),
+		reflect.TypeOf(uint(0)),
+		reflect.TypeOf(uint8(0)),
+		reflect.TypeOf(uint16(0)),
+		reflect.TypeOf(uint32(0)),
+		reflect.TypeOf(uint64(0)),
+		reflect.TypeOf(uintptr(0)),
+		reflect.TypeOf(float32(0)),
+		reflect.TypeOf(float64(0)),
+	}
+
+	rst := ConvMap{}
+	for _, in := range numbers {
+		for _, out := range numbers {
+			if in != out {
+				rst.Set(in, out, convertNumber(out))
+			}
+		}
+
+		rst.Set(in, bool_t, numberToBool)
+		rst.Set(bool_t, in, boolToNumber(in))
+		rst.Set(in, string_t, formatNumber)
+		rst.Set(string_t, in, parseNumber(in))
+	}
+
+	rst.Set(bool_t, string_t, func(v reflect.Value) (any, error) { return strconv.FormatBool(v.Bool()), nil })
+	rst.Set(string_t, bool_t, func(v reflect.Value) (any, error) { return strconv.ParseBool(v.String()) })
+
+	return rst
+}()
+
+// convertNumber returns a conversion of a number into the number type `out`
+// that fails if the value changes.
+func convertNumber(out reflect.Type) func(v reflect.Value) (any, error) {
+	return func(v reflect.Value) (any, error) {
+		rst := reflect.New(out).Elem()
+		switch {
+		case isInt(v.Type()):
+			i := v.Int()
+			switch {
+			case isInt(out):
+				if rst.OverflowInt(i) {
+					return nil, fmt.Errorf("%d overflows %s", i, out)
+				}
+			case isUint(out):
+				if i < 0 || rst.OverflowUint(uint64(i)) {
+					return nil, fmt.Errorf("%d overflows %s", i, out)
+				}
+			case isFloat(out):
+				f := v.Convert(out).Float()
+				if f >= math.MaxInt64 || int64(f) != i {
+					return nil, fmt.Errorf("%d cannot be represented exactly in %s", i, out)
+				}
+			}
+
+		case isUint(v.Type()):
+			u := v.Uint()
+			switch {
+			case isInt(out):
+				if u > math.MaxInt64 || rst.OverflowInt(int64(u)) {
+					return nil, fmt.Errorf("%d overflows %s", u, out)
+				}
+			case isUint(out):
+				if rst.OverflowUint(u) {
+					return nil, fmt.Errorf("%d overflows %s", u, out)
+				}
+			case isFloat(out):
+				f := v.Convert(out).Float()
+				if f >= math.MaxUint64 || uint64(f) != u {
+					return nil, fmt.Errorf("%d cannot be represented exactly in %s", u, out)
+				}
+			}
+
+		case isFloat(v.Type()):
+			f := v.Float()
+			switch {
+			case isInt(out), isUint(out):
+				if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) {
+					return nil, fmt.Errorf("%v is not an integer", f)
+				}
+
+				lo, hi := -math.Ldexp(1, out.Bits()-1), math.Ldexp(1, out.Bits()-1)
+				if isUint(out) {
+					lo, hi = 0, math.Ldexp(1, out.Bits())
+				}
+				if f < lo || f >= hi {
+					return nil, fmt.Errorf("%v overflows %s", f, out)
+				}
+			case isFloat(out):
+				if rst.OverflowFloat(f) {
+					return nil, fmt.Errorf("%v overflows %s", f, out)
+				}
+				// Decimal literals are float64s, so a float32 keeps the shortest decimal form rather than the exact value.
+				if out.Kind() == reflect.Float32 && strconv.FormatFloat(f, 'g', -1, 64) != strconv.FormatFloat(float64(float32(f)), 'g', -1, 32) {
+					return nil, fmt.Errorf("%v cannot be represented in %s", f, out)
+				}
+			}
+		}
+
+		return v.Convert(out).Interface(), nil
+	}
+}
+
+func numberToBool(v reflect.Value) (any, error) {
+	f := v.Convert(reflect.TypeOf(float64(0))).Float()
+	switch f {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+
+	return nil, fmt.Errorf("%v is not 0 or 1", v.Interface())
+}
+
+func boolToNumber(out reflect.Type) func(v reflect.Value) (any, error) {
+	return func(v reflect.Value) (any, error) {
+		i := 0
+		if v.Bool() {
+			i = 1
+		}
+
+		return reflect.ValueOf(i).Convert(out).Interface(), nil
+	}
+}
+
+func formatNumber(v reflect.Value) (any, error) {
+	switch {
+	case isInt(v.Type()):
+		return strconv.FormatInt(v.Int(), 10), nil
+	case isUint(v.Type()):
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+
+	return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+}
+
+// parseNumber returns a conversion of a string into the number type `out`.
+// Integers are decimal unless they have a base prefix "0x", "0o", or "0b",
+// so a leading zero as in "010" does not make it octal.
+func parseNumber(out reflect.Type) func(v reflect.Value) (any, error) {
+	return func(v reflect.Value) (any, error) {
+		rst := reflect.New(out).Elem()
+		switch {
+		case isInt(out):
+			i, err := strconv.ParseInt(v.String(), integerBase(v.String()), out.Bits())
+			if err != nil {
+				return nil, err
+			}
+
+			rst.SetInt(i)
+		case isUint(out):
+			u, err := strconv.ParseUint(v.String(), integerBase(v.String()), out.Bits())
+			if err != nil {
+				return nil, err
+			}
+
+			rst.SetUint(u)
+		default:
+			f, err := strconv.ParseFloat(v.String(), out.Bits())
+			if err != nil {
+				return nil, err
+			}
+
+			rst.SetFloat(f)
+		}
+
+		return rst.Interface(), nil
+	}
+}
+
+// integerBase returns 0 for strconv.ParseInt to take the base from the prefix if the string has one, or 10 otherwise.
+func integerBase(s string) int {
+	s = strings.TrimLeft(s, "+-")
+	if len(s) > 2 && s[0] == '0' && strings.ContainsRune("xXoObB", rune(s[1])) {
+		return 0
+	}
+
+	return 10
+}
+
+// Find finds a conversion from type `in` to type `out`.
+// If there is no direct conversion, it chains up to 3 conversions,
+// preferring the chain with the fewest lossy conversions and then the shortest one.
+// Besides the conversions set for types, conversions set by SetKind and SetInterface
+// and implicit ones are used as described in ConvMap.edges.
+// If there is no chain, lists and maps are converted element-wise, maps and structs are decoded into structs,
+// and pointers are taken or dereferenced as described in ConvMap.composite. Otherwise, the `out` type that implements json.Unmarshaler
+// is decoded from JSON encoding of the value, which uses json.Marshaler if the value implements it.
+func (m ConvMap) Find(out reflect.Type, in reflect.Type) (func(v reflect.Value) (any, error), error) {
+	return m.find(out, in, false)
+}
+
+// find finds a conversion as Find does.
+// If `strict` is true, conversions that can lose information by kinds of the types are not used
+// and ErrLossy is returned if there are only such conversions.
+func (m ConvMap) find(out reflect.Type, in reflect.Type, strict bool) (func(v reflect.Value) (any, error), error) {
+	if in == nil {
+		// Type of nil interface.
 		return nil, ErrNotFound
 	}
+	if strict && isLossy(out, in) {
+		// Chains such as float64 -> string -> int are lossy as a whole.
+		if _, err := m.find(out, in, false); err != nil {
+			return nil, err
+		}
 
-	conv, ok := convs[out]
-	if !ok {
+		return nil, ErrLossy
+	}
+	if conv, ok := m[in][out]; ok {
+		return conv, nil
+	}
+
+	ifaces := m.interfaces()
+	path, err := m.findPath(out, in, strict, ifaces)
+	if err != nil {
+		if conv, ok, err := m.composite(out, in, strict); ok {
+			return conv, err
+		}
+		if canUnmarshal(out, json_unmarshaler_t) {
+			return unmarshalJSON(out), nil
+		}
+		if strict {
+			if _, err := m.find(out, in, false); err == nil {
+				return nil, ErrLossy
+			}
+		}
+
+		return nil, err
+	}
+
+	convs := make([]func(v reflect.Value) (any, error), len(path)-1)
+	for i := range convs {
+		convs[i] = m.edges(path[i], out, ifaces)[path[i+1]]
+	}
+	if len(convs) == 1 {
+		return convs[0], nil
+	}
+
+	return func(v reflect.Value) (any, error) {
+		for i, conv := range convs {
+			if !v.IsValid() {
+				return nil, fmt.Errorf("via %s: %s is nil", pathString(path), path[i])
+			}
+
+			rst, err := conv(v)
+			if err != nil {
+				from, to := "intermediate type "+path[i].String(), "intermediate type "+path[i+1].String()
+				if i == 0 {
+					from = path[i].String()
+				}
+				if i == len(convs)-1 {
+					to = path[i+1].String()
+				}
+
+				return nil, fmt.Errorf("via %s: convert to %s from %s: %w", pathString(path), to, from, err)
+			}
+
+			v = reflect.ValueOf(rst)
+		}
+
+		return v.Interface(), nil
+	}, nil
+}
+
+// SetKind sets a conversion from any type of kind `from` to type `to`.
+// It is used if there is no conversion set for the type itself.
+func (m ConvMap) SetKind(from reflect.Kind, to reflect.Type, conv func(v reflect.Value) (any, error)) {
+	m.Set(kindType(from), to, conv)
+}
+
+// SetInterface sets a conversion from any type that implements the interface `from` to type `to`.
+// It is used if there is no conversion set for the type itself or its kind.
+func (m ConvMap) SetInterface(from reflect.Type, to reflect.Type, conv func(v reflect.Value) (any, error)) {
+	m.Set(from, to, conv)
+}
+
+type kindKey struct{}
+
+// kindType returns a type that stands for the kind as a key of ConvMap.
+func kindType(k reflect.Kind) reflect.Type {
+	return reflect.ArrayOf(int(k), reflect.TypeOf(kindKey{}))
+}
+
+// interfaces returns interface types that have conversions in order of their names.
+func (m ConvMap) interfaces() []reflect.Type {
+	rst := []reflect.Type{}
+	for t := range m {
+		if t.Kind() == reflect.Interface {
+			rst = append(rst, t)
+		}
+	}
+
+	sort.Slice(rst, func(i, j int) bool { return rst[i].String() < rst[j].String() })
+	return rst
+}
+
+// edges returns conversions from type `t` to types it converts to without intermediate types.
+// Conversions set for the type take precedence over ones for its kind, interfaces it implements, and then implicit ones:
+//   - a named type of a basic kind converts to and from the predeclared type of the kind, e.g. `Port` and `int`.
+//   - fmt.Stringer, or encoding.TextMarshaler if not, converts to string.
+//   - string converts to encoding.TextUnmarshaler.
+//
+// `out` is the type to be reached and `ifaces` are given by ConvMap.interfaces.
+func (m ConvMap) edges(t reflect.Type, out reflect.Type, ifaces []reflect.Type) map[reflect.Type]func(v reflect.Value) (any, error) {
+	rst := map[reflect.Type]func(v reflect.Value) (any, error){}
+	add := func(u reflect.Type, conv func(v reflect.Value) (any, error)) {
+		if _, ok := rst[u]; !ok && u != t {
+			rst[u] = conv
+		}
+	}
+
+	for u, conv := range m[t] {
+		add(u, conv)
+	}
+	for u, conv := range m[kindType(t.Kind())] {
+		add(u, conv)
+	}
+	for _, i := range ifaces {
+		if t.Implements(i) {
+			for u, conv := range m[i] {
+				add(u, conv)
+			}
+		}
+	}
+
+	if base, ok := basic_types[t.Kind()]; ok {
+		add(base, convertKind(base))
+	}
+	if base, ok := basic_types[out.Kind()]; ok && t == base {
+		add(out, convertKind(out))
+	}
+	if t.Implements(stringer_t) {
+		add(string_t, func(v reflect.Value) (any, error) { return v.Interface().(fmt.Stringer).String(), nil })
+	}
+	if t.Implements(text_marshaler_t) {
+		add(string_t, func(v reflect.Value) (any, error) {
+			text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			return string(text), err
+		})
+	}
+	if t == string_t && canUnmarshal(out, text_unmarshaler_t) {
+		add(out, func(v reflect.Value) (any, error) {
+			return unmarshal(out, func(u any) error { return u.(encoding.TextUnmarshaler).UnmarshalText([]byte(v.String())) })
+		})
+	}
+
+	return rst
+}
+
+// next returns types that a value of type `t` converts to in one step
+// in order of their names.
+func (m ConvMap) next(t reflect.Type, out reflect.Type, ifaces []reflect.Type) []reflect.Type {
+	edges := m.edges(t, out, ifaces)
+	rst := make([]reflect.Type, 0, len(edges))
+	for u := range edges {
+		rst = append(rst, u)
+	}
+
+	sort.Slice(rst, func(i, j int) bool { return rst[i].String() < rst[j].String() })
+	return rst
+}
+
+// basic_types are the predeclared types of basic kinds.
+var basic_types = func() map[reflect.Kind]reflect.Type {
+	rst := map[reflect.Kind]reflect.Type{}
+	for _, v := range []any{
+		false, "",
+		int(0), int8(0), int16(0), int32(0), int64(0),
+		uint(0), uint8(0), uint16(0), uint32(0), uint64(0), uintptr(0),
+		float32(0), float64(0), complex64(0), complex128(0),
+	} {
+		t := reflect.TypeOf(v)
+		rst[t.Kind()] = t
+	}
+
+	return rst
+}()
+
+func convertKind(out reflect.Type) func(v reflect.Value) (any, error) {
+	return func(v reflect.Value) (any, error) { return v.Convert(out).Interface(), nil }
+}
+
+// canUnmarshal reports whether a value of type `t` can be decoded by the unmarshaler interface `i`.
+func canUnmarshal(t reflect.Type, i reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return false
+	case reflect.Pointer:
+		return t.Implements(i)
+	}
+
+	return reflect.PointerTo(t).Implements(i)
+}
+
+// unmarshal returns a value of type `t` decoded by `decode` that is given a pointer to the value.
+func unmarshal(t reflect.Type, decode func(u any) error) (any, error) {
+	if t.Kind() == reflect.Pointer {
+		p := reflect.New(t.Elem())
+		if err := decode(p.Interface()); err != nil {
+			return nil, err
+		}
+
+		return p.Interface(), nil
+	}
+
+	p := reflect.New(t)
+	if err := decode(p.Interface()); err != nil {
+		return nil, err
+	}
+
+	return p.Elem().Interface(), nil
+}
+
+// unmarshalJSON converts a value into type `t` that implements json.Unmarshaler
+// by decoding JSON encoding of the value.
+func unmarshalJSON(t reflect.Type) func(v reflect.Value) (any, error) {
+	return func(v reflect.Value) (any, error) {
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		return unmarshal(t, func(u any) error { return u.(json.Unmarshaler).UnmarshalJSON(data) })
+	}
+}
+
+type convPath struct {
+	types []reflect.Type
+	lossy int
+
+	// Number of implicit conversions between a named type and the predeclared type of its kind.
+	implicit int
+}
+
+func (p convPath) better(q convPath) bool {
+	if p.lossy != q.lossy {
+		return p.lossy < q.lossy
+	}
+
+	return p.implicit < q.implicit
+}
+
+// findPath finds types of the conversion chain from type `in` to type `out`.
+// The returned error tells chains that are tried if there is no chain.
+// `ifaces` are given by ConvMap.interfaces.
+func (m ConvMap) findPath(out reflect.Type, in reflect.Type, strict bool, ifaces []reflect.Type) ([]reflect.Type, error) {
+	// Best chains of the current length to each type.
+	level := map[reflect.Type]convPath{in: {types: []reflect.Type{in}}}
+
+	// Types reached by chains that are tried.
+	reached := map[reflect.Type]convPath{}
+
+	var found *convPath
+	for hops := 1; hops <= maxConvHops && len(level) > 0; hops++ {
+		ts := make([]reflect.Type, 0, len(level))
+		for t := range level {
+			ts = append(ts, t)
+		}
+		sort.Slice(ts, func(i, j int) bool { return ts[i].String() < ts[j].String() })
+
+		next := map[reflect.Type]convPath{}
+		for _, t := range ts {
+			p := level[t]
+			// A hop from a string is judged from the type before it, e.g. int -> string -> float64 is lossy.
+			from := t
+			if t.Kind() == reflect.String && len(p.types) > 1 {
+				from = p.types[len(p.types)-2]
+			}
+			for _, u := range m.next(t, out, ifaces) {
+				lossy := isLossy(u, t) || isLossy(u, from)
+				if hasType(p.types, u) || (strict && lossy) {
+					continue
+				}
+
+				c := convPath{
+					types:    append(append(make([]reflect.Type, 0, len(p.types)+1), p.types...), u),
+					lossy:    p.lossy,
+					implicit: p.implicit,
+				}
+				if lossy {
+					c.lossy++
+				}
+				if _, ok := m[t][u]; !ok && u.Kind() == t.Kind() {
+					c.implicit++
+				}
+				if q, ok := next[u]; ok && !c.better(q) {
+					continue
+				}
+
+				next[u] = c
+			}
+		}
+
+		if p, ok := next[out]; ok && (found == nil || p.better(*found)) {
+			found = &p
+		}
+		if found != nil && found.lossy == 0 && found.implicit == 0 {
+			break
+		}
+
+		for u, p := range next {
+			if _, ok := reached[u]; !ok {
+				reached[u] = p
+			}
+		}
+
+		level = next
+	}
+	if found != nil {
+		return found.types, nil
+	}
+	if len(reached) == 0 {
 		return nil, ErrNotFound
 	}
 
-	return conv(v)
+	paths := make([]string, 0, len(reached))
+	for _, p := range reached {
+		paths = append(paths, pathString(p.types))
+	}
+	sort.Strings(paths)
+
+	return nil, fmt.Errorf("%w: tried %s", ErrNotFound, strings.Join(paths, ", "))
 }
 
-func (m ConvMap) ConvertTo(out reflect.Type, in any) (any, error) {
-	return m.Convert(out, reflect.TypeOf(in), reflect.ValueOf(in))
+// composite returns an element-wise conversion between lists, between maps,
+// from a map or a struct whose fields are all in the `out` struct into a struct as ConvMap.decodeStruct does,
+// or one that takes or gives a pointer to the value.
+// It reports false if the types are not such composites.
+func (m ConvMap) composite(out reflect.Type, in reflect.Type, strict bool) (func(v reflect.Value) (any, error), bool, error) {
+	switch {
+	case out.Kind() == reflect.Pointer && in.Kind() == reflect.Pointer:
+		elem, err := m.elem(out.Elem(), in.Elem(), strict)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return func(v reflect.Value) (any, error) {
+			if v.IsNil() {
+				return reflect.Zero(out).Interface(), nil
+			}
+
+			u, err := elem(v.Elem())
+			if err != nil {
+				return nil, err
+			}
+
+			p := reflect.New(out.Elem())
+			p.Elem().Set(u)
+			return p.Interface(), nil
+		}, true, nil
+
+	case out.Kind() == reflect.Pointer:
+		elem, err := m.elem(out.Elem(), in, strict)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return func(v reflect.Value) (any, error) {
+			u, err := elem(v)
+			if err != nil {
+				return nil, err
+			}
+
+			p := reflect.New(out.Elem())
+			p.Elem().Set(u)
+			return p.Interface(), nil
+		}, true, nil
+
+	case in.Kind() == reflect.Pointer:
+		elem, err := m.elem(out, in.Elem(), strict)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return func(v reflect.Value) (any, error) {
+			if v.IsNil() {
+				return nil, fmt.Errorf("%s is nil", in)
+			}
+
+			u, err := elem(v.Elem())
+			if err != nil {
+				return nil, err
+			}
+
+			return u.Interface(), nil
+		}, true, nil
+
+	case isList(out) && isList(in):
+		elem, err := m.elem(out.Elem(), in.Elem(), strict)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return func(v reflect.Value) (any, error) {
+			n := v.Len()
+
+			var rst reflect.Value
+			switch {
+			case out.Kind() == reflect.Array:
+				if n != out.Len() {
+					return nil, fmt.Errorf("%d elements are given but %s has %d", n, out, out.Len())
+				}
+
+				rst = reflect.New(out).Elem()
+			case v.Kind() == reflect.Slice && v.IsNil():
+				return reflect.Zero(out).Interface(), nil
+			default:
+				rst = reflect.MakeSlice(out, n, n)
+			}
+
+			for i := 0; i < n; i++ {
+				u, err := elem(v.Index(i))
+				if err != nil {
+					return nil, fmt.Errorf("[%d]: %w", i, err)
+				}
+
+				rst.Index(i).Set(u)
+			}
+
+			return rst.Interface(), nil
+		}, true, nil
+
+	case out.Kind() == reflect.Struct && !canUnmarshal(out, json_unmarshaler_t):
+		switch {
+		case in.Kind() == reflect.Struct:
+			// A struct without exported fields, such as time.Time, is opaque rather than empty.
+			in_fields := fieldsOf(in)
+			if len(in_fields) == 0 {
+				return nil, false, nil
+			}
+
+			fields := fieldsOf(out)
+			for _, f := range in_fields {
+				if _, ok := lookupField(fields, f.name); !ok {
+					return nil, false, nil
+				}
+			}
+		case in.Kind() == reflect.Map && (in.Key().Kind() == reflect.String || in.Key().Kind() == reflect.Interface):
+
+		default:
+			return nil, false, nil
+		}
+
+		return m.decodeStruct(out, strict), true, nil
+
+	case out.Kind() == reflect.Map && in.Kind() == reflect.Map:
+		key, err := m.elem(out.Key(), in.Key(), strict)
+		if err != nil {
+			return nil, true, fmt.Errorf("key: %w", err)
+		}
+		elem, err := m.elem(out.Elem(), in.Elem(), strict)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return func(v reflect.Value) (any, error) {
+			if v.IsNil() {
+				return reflect.Zero(out).Interface(), nil
+			}
+
+			rst := reflect.MakeMapWithSize(out, v.Len())
+			for _, k := range sortedKeys(v) {
+				k_out, err := key(k)
+				if err != nil {
+					return nil, fmt.Errorf("key %v: %w", k, err)
+				}
+
+				u, err := elem(v.MapIndex(k))
+				if err != nil {
+					return nil, fmt.Errorf("[%v]: %w", k, err)
+				}
+
+				rst.SetMapIndex(k_out, u)
+			}
+
+			return rst.Interface(), nil
+		}, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// elem returns a conversion of an element of type `in` into type `out`.
+// An element of interface type is converted from its dynamic type.
+func (m ConvMap) elem(out reflect.Type, in reflect.Type, strict bool) (func(v reflect.Value) (reflect.Value, error), error) {
+	if in.Kind() == reflect.Interface && !in.AssignableTo(out) {
+		return func(v reflect.Value) (reflect.Value, error) {
+			if v.IsNil() {
+				switch out.Kind() {
+				case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+					return reflect.Zero(out), nil
+				}
+
+				return reflect.Value{}, &conversionError{out: out, err: errors.New("type cannot be nil")}
+			}
+
+			conv, err := m.elem(out, v.Elem().Type(), strict)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			return conv(v.Elem())
+		}, nil
+	}
+	if in.AssignableTo(out) {
+		return func(v reflect.Value) (reflect.Value, error) { return v, nil }, nil
+	}
+
+	conv, err := m.find(out, in, strict)
+	if err != nil {
+		return nil, &conversionError{out: out, in: in, err: err}
+	}
+
+	return func(v reflect.Value) (reflect.Value, error) {
+		rst, err := conv(v)
+		if err != nil {
+			return reflect.Value{}, &conversionError{out: out, in: in, err: err}
+		}
+		if rst == nil {
+			return reflect.Zero(out), nil
+		}
+
+		return reflect.ValueOf(rst), nil
+	}, nil
+}
+
+func isList(t reflect.Type) bool {
+	return t.Kind() == reflect.Array || t.Kind() == reflect.Slice
+}
+
+func hasType(ts []reflect.Type, t reflect.Type) bool {
+	for _, u := range ts {
+		if u == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func pathString(ts []reflect.Type) string {
+	names := make([]string, len(ts))
+	for i, t := range ts {
+		names[i] = t.String()
+	}
+
+	return strings.Join(names, " -> ")
+}
+
+// isLossy reports whether a conversion from type `in` to type `out` can lose information
+// judging by their kinds, such as float to int or int64 to int8.
+func isLossy(out reflect.Type, in reflect.Type) bool {
+	if out.Kind() == in.Kind() {
+		return false
+	}
+
+	switch {
+	case isInt(in) && isInt(out), isUint(in) && isUint(out), isFloat(in) && isFloat(out):
+		return out.Bits() < in.Bits()
+	case isUint(in) && isInt(out):
+		return out.Bits() <= in.Bits()
+	case isInt(in) && isUint(out):
+		return true
+	case (isInt(in) || isUint(in)) && isFloat(out):
+		mantissa := 24
+		if out.Kind() == reflect.Float64 {
+			mantissa = 53
+		}
+
+		return in.Bits() > mantissa
+	case isFloat(in) && (isInt(out) || isUint(out)):
+		return true
+	case (isInt(in) || isUint(in) || isFloat(in)) && out.Kind() == reflect.Bool:
+		return true
+	}
+
+	return false
+}
+
+func isInt(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+
+	return false
+}
+
+func isUint(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+
+	return false
 }
 
-var defaultConversions = ConvMap{
-	reflect.TypeOf(int(0)): map[reflect.Type]func(v reflect.Value) (any, error){
-		reflect.TypeOf(string("")): func(v reflect.Value) (any, error) { return strconv.Itoa(int(v.Int())), nil },
-		reflect.TypeOf(float64(0)): func(v reflect.Value) (any, error) { return float64(v.Int()), nil },
-	},
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
 }
@@ -1,8 +1,14 @@
 package pl_test
 
 import (
+	"encoding"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/lesomnus/pl"
@@ -97,9 +103,85 @@ func TestConvMapDefaultConversions(t *testing.T) {
 			in:  42,
 			out: 42.0,
 		},
+		{
+			in:  42,
+			out: uint16(42),
+		},
+		{
+			in:  -42,
+			out: int8(-42),
+		},
+		{
+			in:  1,
+			out: true,
+		},
+		// from uint
+		{
+			in:  uint64(math.MaxUint64),
+			out: "18446744073709551615",
+		},
+		{
+			in:  uint8(255),
+			out: int16(255),
+		},
+		// from float
+		{
+			in:  42.0,
+			out: int64(42),
+		},
+		{
+			in:  3.5,
+			out: float32(3.5),
+		},
+		{
+			in:  0.1,
+			out: float32(0.1),
+		},
+		{
+			in:  float32(0.1),
+			out: "0.1",
+		},
+		{
+			in:  0.0,
+			out: false,
+		},
+		// from bool
+		{
+			in:  true,
+			out: uint(1),
+		},
+		{
+			in:  false,
+			out: "false",
+		},
+		// from string
+		{
+			in:  "3",
+			out: 3,
+		},
+		{
+			in:  "0x1f",
+			out: uint8(31),
+		},
+		{
+			in:  "-0b11",
+			out: int8(-3),
+		},
+		{
+			in:  "010",
+			out: 10,
+		},
+		{
+			in:  "-2.5",
+			out: float32(-2.5),
+		},
+		{
+			in:  "true",
+			out: true,
+		},
 	}
 	for _, tc := range tcs {
-		t.Run(fmt.Sprintf("from %s to %s", reflect.TypeOf(tc.in).Name(), reflect.TypeOf(tc.out).Name()), func(t *testing.T) {
+		t.Run(fmt.Sprintf("from %s %v to %s", reflect.TypeOf(tc.in).Name(), tc.in, reflect.TypeOf(tc.out).Name()), func(t *testing.T) {
 			require := require.New(t)
 
 			v, err := convs.ConvertTo(reflect.TypeOf(tc.out), tc.in)
@@ -107,4 +189,565 @@ func TestConvMapDefaultConversions(t *testing.T) {
 			require.Equal(tc.out, v)
 		})
 	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			in   any
+			out  any
+			msgs []string
+		}{
+			{
+				in:   300,
+				out:  int8(0),
+				msgs: []string{"300 overflows int8"},
+			},
+			{
+				in:   -1,
+				out:  uint(0),
+				msgs: []string{"-1 overflows uint"},
+			},
+			{
+				in:   uint64(math.MaxUint64),
+				out:  int64(0),
+				msgs: []string{"overflows int64"},
+			},
+			{
+				in:   math.MaxInt64,
+				out:  0.0,
+				msgs: []string{"cannot be represented exactly in float64"},
+			},
+			{
+				in:   1<<24 + 1,
+				out:  float32(0),
+				msgs: []string{"cannot be represented exactly in float32"},
+			},
+			{
+				in:   3.14,
+				out:  0,
+				msgs: []string{"3.14 is not an integer"},
+			},
+			{
+				in:   256.0,
+				out:  uint8(0),
+				msgs: []string{"256 overflows uint8"},
+			},
+			{
+				in:   -1.0,
+				out:  uint(0),
+				msgs: []string{"-1 overflows uint"},
+			},
+			{
+				in:   math.MaxFloat64,
+				out:  float32(0),
+				msgs: []string{"overflows float32"},
+			},
+			{
+				in:   3.14159265,
+				out:  float32(0),
+				msgs: []string{"3.14159265 cannot be represented in float32"},
+			},
+			{
+				in:   2,
+				out:  false,
+				msgs: []string{"2 is not 0 or 1"},
+			},
+			{
+				in:   "256",
+				out:  uint8(0),
+				msgs: []string{"out of range"},
+			},
+			{
+				in:   "1_000",
+				out:  0,
+				msgs: []string{"invalid syntax"},
+			},
+			{
+				in:   "Rick",
+				out:  0.0,
+				msgs: []string{"invalid syntax"},
+			},
+			{
+				in:   "yes",
+				out:  false,
+				msgs: []string{"invalid syntax"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(fmt.Sprintf("from %s %v to %s", reflect.TypeOf(tc.in).Name(), tc.in, reflect.TypeOf(tc.out).Name()), func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := convs.ConvertTo(reflect.TypeOf(tc.out), tc.in)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestConvMapFind(t *testing.T) {
+	string_t := reflect.TypeOf("")
+
+	type A struct{ V int }
+	type B struct{ V int }
+	type C struct{ V int }
+	type D struct{ V int }
+	type E struct{ W int }
+
+	a_t := reflect.TypeOf(A{})
+	b_t := reflect.TypeOf(B{})
+	c_t := reflect.TypeOf(C{})
+	d_t := reflect.TypeOf(D{})
+	e_t := reflect.TypeOf(E{})
+	int_t := reflect.TypeOf(0)
+	int8_t := reflect.TypeOf(int8(0))
+	float64_t := reflect.TypeOf(0.0)
+
+	convs := pl.ConvMap{}
+	convs.Set(a_t, b_t, func(v reflect.Value) (any, error) { return B{V: v.Interface().(A).V + 1}, nil })
+	convs.Set(b_t, c_t, func(v reflect.Value) (any, error) { return C{V: v.Interface().(B).V * 10}, nil })
+	convs.Set(c_t, d_t, func(v reflect.Value) (any, error) { return D{V: v.Interface().(C).V}, nil })
+	convs.Set(d_t, e_t, func(v reflect.Value) (any, error) { return E{W: v.Interface().(D).V}, nil })
+	convs.Set(c_t, int_t, func(v reflect.Value) (any, error) { return v.Interface().(C).V, nil })
+
+	t.Run("chain of conversions", func(t *testing.T) {
+		require := require.New(t)
+
+		v, err := convs.ConvertTo(c_t, A{V: 1})
+		require.NoError(err)
+		require.Equal(C{V: 20}, v)
+
+		v, err = convs.ConvertTo(d_t, A{V: 1})
+		require.NoError(err)
+		require.Equal(D{V: 20}, v)
+	})
+
+	t.Run("lossless chain is preferred", func(t *testing.T) {
+		require := require.New(t)
+
+		convs := pl.ConvMap{}
+		convs.Set(a_t, int8_t, func(v reflect.Value) (any, error) { return int8(1), nil })
+		convs.Set(int8_t, float64_t, func(v reflect.Value) (any, error) { return 1.0, nil })
+		convs.Set(a_t, int_t, func(v reflect.Value) (any, error) { return 2, nil })
+		convs.Set(int_t, float64_t, func(v reflect.Value) (any, error) { return 2.0, nil })
+		convs.Set(int_t, int8_t, func(v reflect.Value) (any, error) { return int8(2), nil })
+		convs.Set(int8_t, b_t, func(v reflect.Value) (any, error) { return B{V: int(v.Int())}, nil })
+
+		// A -> int -> float64 is lossy.
+		v, err := convs.ConvertTo(float64_t, A{})
+		require.NoError(err)
+		require.Equal(1.0, v)
+
+		// A -> int -> int8 -> B is lossy.
+		v, err = convs.ConvertTo(b_t, A{})
+		require.NoError(err)
+		require.Equal(B{V: 1}, v)
+	})
+
+	t.Run("fails if chain is too long", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := convs.ConvertTo(e_t, A{})
+		require.ErrorIs(err, pl.ErrNotFound)
+		require.ErrorContains(err, "pl_test.A -> pl_test.B -> pl_test.C -> pl_test.D")
+	})
+
+	t.Run("fails if conversion in chain fails", func(t *testing.T) {
+		require := require.New(t)
+
+		convs := pl.ConvMap{}
+		convs.Set(a_t, b_t, func(v reflect.Value) (any, error) { return B{}, nil })
+		convs.Set(b_t, string_t, func(v reflect.Value) (any, error) { return "", errors.New("failed") })
+		convs.Set(string_t, e_t, func(v reflect.Value) (any, error) { return E{}, nil })
+
+		_, err := convs.ConvertTo(e_t, A{})
+		require.ErrorContains(err, "pl_test.A -> pl_test.B -> string -> pl_test.E")
+		require.ErrorContains(err, "convert to intermediate type string from intermediate type pl_test.B: failed")
+	})
+
+	t.Run("fails if value is nil", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := convs.ConvertTo(e_t, nil)
+		require.ErrorIs(err, pl.ErrNotFound)
+
+		_, err = pl.NewConvMap().ConvertTo(int_t, nil)
+		require.ErrorIs(err, pl.ErrNotFound)
+	})
+}
+
+type port int
+
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte([]string{"debug", "info"}[l]), nil
+}
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+
+	return nil
+}
+
+type point struct{ X, Y int }
+
+func (p *point) UnmarshalJSON(data []byte) error {
+	vs := []int{}
+	if err := json.Unmarshal(data, &vs); err != nil {
+		return err
+	}
+	if len(vs) != 2 {
+		return errors.New("point must have 2 coordinates")
+	}
+
+	p.X, p.Y = vs[0], vs[1]
+	return nil
+}
+
+type pair struct{ L, R int }
+
+func (p pair) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{p.L, p.R})
+}
+
+func TestConvMapKind(t *testing.T) {
+	type A struct{ V int }
+
+	string_t := reflect.TypeOf("")
+	a_t := reflect.TypeOf(A{})
+	port_t := reflect.TypeOf(port(0))
+
+	convs := pl.NewConvMap()
+	convs.Set(reflect.TypeOf(0), a_t, func(v reflect.Value) (any, error) { return A{V: int(v.Int())}, nil })
+	convs.SetKind(reflect.Slice, string_t, func(v reflect.Value) (any, error) {
+		vs := make([]string, v.Len())
+		for i := range vs {
+			vs[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+
+		return strings.Join(vs, ","), nil
+	})
+	convs.Set(reflect.TypeOf([]bool{}), string_t, func(v reflect.Value) (any, error) { return "bools", nil })
+
+	tcs := []struct {
+		desc     string
+		in       any
+		expected any
+	}{
+		{
+			desc:     "conversion of predeclared type is used for named type",
+			in:       port(80),
+			expected: A{V: 80},
+		},
+		{
+			desc:     "named type is converted from predeclared type",
+			in:       8080,
+			expected: port(8080),
+		},
+		{
+			desc:     "named type is converted from string",
+			in:       "8080",
+			expected: port(8080),
+		},
+		{
+			desc:     "named type is converted to string",
+			in:       port(80),
+			expected: "80",
+		},
+		{
+			desc:     "conversion of kind",
+			in:       []int{1, 2},
+			expected: "1,2",
+		},
+		{
+			desc:     "conversion of type takes precedence over one of kind",
+			in:       []bool{true},
+			expected: "bools",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			v, err := convs.ConvertTo(reflect.TypeOf(tc.expected), tc.in)
+			require.NoError(err)
+			require.Equal(tc.expected, v)
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := convs.ConvertTo(port_t, "http")
+		require.ErrorContains(err, "via string -> int -> pl_test.port")
+		require.ErrorContains(err, "invalid syntax")
+	})
+}
+
+func TestConvMapInterface(t *testing.T) {
+	type A struct{ V string }
+
+	a_t := reflect.TypeOf(A{})
+	level_t := reflect.TypeOf(level(0))
+	point_t := reflect.TypeOf(point{})
+
+	convs := pl.NewConvMap()
+	convs.SetInterface(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(), a_t, func(v reflect.Value) (any, error) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return A{V: string(text)}, err
+	})
+
+	tcs := []struct {
+		desc     string
+		in       any
+		expected any
+	}{
+		{
+			desc:     "conversion of interface",
+			in:       level(1),
+			expected: A{V: "info"},
+		},
+		{
+			desc:     "TextMarshaler is converted to string",
+			in:       level(0),
+			expected: "debug",
+		},
+		{
+			desc:     "TextUnmarshaler is converted from string",
+			in:       "info",
+			expected: level(1),
+		},
+		{
+			desc:     "pointer to TextUnmarshaler is converted from string",
+			in:       "127.0.0.1",
+			expected: net.ParseIP("127.0.0.1"),
+		},
+		{
+			desc:     "json.Unmarshaler is decoded from JSON",
+			in:       []any{1, 2},
+			expected: point{X: 1, Y: 2},
+		},
+		{
+			desc:     "json.Unmarshaler is decoded from json.Marshaler",
+			in:       pair{L: 3, R: 4},
+			expected: &point{X: 3, Y: 4},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			v, err := convs.ConvertTo(reflect.TypeOf(tc.expected), tc.in)
+			require.NoError(err)
+			require.Equal(tc.expected, v)
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			out  reflect.Type
+			in   any
+			msgs []string
+		}{
+			{
+				desc: "UnmarshalText fails",
+				out:  level_t,
+				in:   "warn",
+				msgs: []string{`unknown level "warn"`},
+			},
+			{
+				desc: "UnmarshalJSON fails",
+				out:  point_t,
+				in:   []any{1},
+				msgs: []string{"point must have 2 coordinates"},
+			},
+			{
+				desc: "value cannot be encoded to JSON",
+				out:  point_t,
+				in:   func() {},
+				msgs: []string{"unsupported type"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := convs.ConvertTo(tc.out, tc.in)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestConvMapComposite(t *testing.T) {
+	type Config struct{ Name string }
+
+	convs := pl.NewConvMap()
+
+	one := 1
+	tcs := []struct {
+		desc     string
+		in       any
+		expected any
+	}{
+		{
+			desc:     "slice of interfaces",
+			in:       []any{1, "2", 3.0},
+			expected: []int{1, 2, 3},
+		},
+		{
+			desc:     "slice of values",
+			in:       []int{1, 2},
+			expected: []string{"1", "2"},
+		},
+		{
+			desc:     "nil element",
+			in:       []any{nil, &one},
+			expected: []*int{nil, &one},
+		},
+		{
+			desc:     "nil slice",
+			in:       []any(nil),
+			expected: []int(nil),
+		},
+		{
+			desc:     "array",
+			in:       []any{1, 2},
+			expected: [2]int64{1, 2},
+		},
+		{
+			desc:     "array to slice",
+			in:       [2]int{1, 2},
+			expected: []float64{1, 2},
+		},
+		{
+			desc:     "nested",
+			in:       []any{[]any{"1"}, []int{2}},
+			expected: [][]int{{1}, {2}},
+		},
+		{
+			desc:     "map",
+			in:       map[string]any{"1": "2"},
+			expected: map[int]int{1: 2},
+		},
+		{
+			desc:     "pointer is given",
+			in:       &Config{Name: "Rick"},
+			expected: Config{Name: "Rick"},
+		},
+		{
+			desc:     "pointer is taken",
+			in:       Config{Name: "Rick"},
+			expected: &Config{Name: "Rick"},
+		},
+		{
+			desc:     "pointer to converted value",
+			in:       "42",
+			expected: func() *int { v := 42; return &v }(),
+		},
+		{
+			desc:     "pointer to pointer",
+			in:       &one,
+			expected: func() *string { s := "1"; return &s }(),
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			v, err := convs.ConvertTo(reflect.TypeOf(tc.expected), tc.in)
+			require.NoError(err)
+			require.Equal(tc.expected, v)
+		})
+	}
+
+	t.Run("registered conversion takes precedence", func(t *testing.T) {
+		require := require.New(t)
+
+		convs := pl.NewConvMap()
+		convs.Set(reflect.TypeOf([]any{}), reflect.TypeOf([]int{}), func(v reflect.Value) (any, error) { return []int{42}, nil })
+
+		v, err := convs.ConvertTo(reflect.TypeOf([]int{}), []any{"1"})
+		require.NoError(err)
+		require.Equal([]int{42}, v)
+	})
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			out  reflect.Type
+			in   any
+			msgs []string
+		}{
+			{
+				desc: "element cannot be converted",
+				out:  reflect.TypeOf([]int{}),
+				in:   []any{1, "Rick"},
+				msgs: []string{`[1]: convert to int from string: strconv.ParseInt: parsing "Rick": invalid syntax`},
+			},
+			{
+				desc: "element is nil",
+				out:  reflect.TypeOf([]int{}),
+				in:   []any{1, nil},
+				msgs: []string{"[1]: convert to int from nil: type cannot be nil"},
+			},
+			{
+				desc: "element of nested list cannot be converted",
+				out:  reflect.TypeOf([][]int{}),
+				in:   []any{[]any{1}, []any{2, "x"}},
+				msgs: []string{"[1]: convert to []int from []interface {}: [1]: convert to int from string"},
+			},
+			{
+				desc: "there is no conversion for elements",
+				out:  reflect.TypeOf([]int{}),
+				in:   []Config{{}},
+				msgs: []string{"convert to int from pl_test.Config", "not found"},
+			},
+			{
+				desc: "length of array does not match",
+				out:  reflect.TypeOf([3]int{}),
+				in:   []int{1, 2},
+				msgs: []string{"2 elements are given but [3]int has 3"},
+			},
+			{
+				desc: "key cannot be converted",
+				out:  reflect.TypeOf(map[int]string{}),
+				in:   map[string]string{"a": "b"},
+				msgs: []string{`key a: convert to int from string`},
+			},
+			{
+				desc: "value cannot be converted",
+				out:  reflect.TypeOf(map[string]int{}),
+				in:   map[string]any{"a": "b"},
+				msgs: []string{`[a]: convert to int from string`},
+			},
+			{
+				desc: "pointer is nil",
+				out:  reflect.TypeOf(Config{}),
+				in:   (*Config)(nil),
+				msgs: []string{"*pl_test.Config is nil"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := convs.ConvertTo(tc.out, tc.in)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
 }
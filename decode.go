@@ -0,0 +1,224 @@
+package pl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type structField struct {
+	name     string
+	index    []int
+	required bool
+}
+
+// fieldsOf returns exported fields of the struct including ones promoted from embedded structs.
+// A field is named by the first name given in its `pl`, `json`, or `yaml` tag, or by its name,
+// and skipped if the tag names it "-". A field is required if its `pl` tag has the option "required",
+// as in `pl:"name,required"`.
+func fieldsOf(t reflect.Type) []structField {
+	return fieldsIn(t, map[reflect.Type]bool{t: true})
+}
+
+// fieldsIn returns fields as fieldsOf does.
+// Embedded structs of `visited` types are skipped, as encoding/json does, so a struct that embeds itself terminates.
+func fieldsIn(t reflect.Type, visited map[reflect.Type]bool) []structField {
+	rst := []structField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			u := f.Type
+			if u.Kind() == reflect.Pointer {
+				if !f.IsExported() {
+					continue
+				}
+
+				u = u.Elem()
+			}
+			if u.Kind() == reflect.Struct {
+				if visited[u] {
+					continue
+				}
+
+				visited[u] = true
+				for _, g := range fieldsIn(u, visited) {
+					g.index = append([]int{i}, g.index...)
+					rst = append(rst, g)
+				}
+
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		_, opts, _ := strings.Cut(f.Tag.Get("pl"), ",")
+		rst = append(rst, structField{
+			name:     name,
+			index:    []int{i},
+			required: hasOption(opts, "required"),
+		})
+	}
+
+	// A field of the outer struct shadows one of the embedded struct with the same name.
+	dedup := make([]structField, 0, len(rst))
+	for _, f := range rst {
+		shadowed := false
+		for _, g := range rst {
+			if g.name == f.name && len(g.index) < len(f.index) {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			dedup = append(dedup, f)
+		}
+	}
+
+	return dedup
+}
+
+func fieldName(f reflect.StructField) (string, bool) {
+	for _, key := range []string{"pl", "json", "yaml"} {
+		name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+		switch name {
+		case "":
+			continue
+		case "-":
+			return "", false
+		}
+
+		return name, true
+	}
+
+	return "", true
+}
+
+func hasOption(opts string, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupField finds the field of the name, or one whose name matches case-insensitively.
+func lookupField(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+
+	return structField{}, false
+}
+
+type entry struct {
+	key   string
+	value reflect.Value
+}
+
+// entriesOf returns entries of the map in order of their keys or fields of the struct.
+func entriesOf(v reflect.Value) ([]entry, error) {
+	if v.Kind() == reflect.Struct {
+		rst := []entry{}
+		for _, f := range fieldsOf(v.Type()) {
+			u, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				// Field of nil embedded struct.
+				continue
+			}
+
+			rst = append(rst, entry{key: f.name, value: u})
+		}
+
+		return rst, nil
+	}
+
+	keys := sortedKeys(v)
+	rst := make([]entry, len(keys))
+	for i, k := range keys {
+		if k.Kind() == reflect.Interface {
+			k = k.Elem()
+		}
+		if k.Kind() != reflect.String {
+			return nil, fmt.Errorf("key %v is not a string", k)
+		}
+
+		rst[i] = entry{key: k.String(), value: v.MapIndex(keys[i])}
+	}
+
+	return rst, nil
+}
+
+// decodeStruct returns a conversion from a map with string keys or a struct into the struct type `out`.
+// Entries are set to fields of the same names and converted into types of the fields.
+// It fails if the map has a key that is not a field or a required field is not given.
+func (m ConvMap) decodeStruct(out reflect.Type, strict bool) func(v reflect.Value) (any, error) {
+	fields := fieldsOf(out)
+	return func(v reflect.Value) (any, error) {
+		entries, err := entriesOf(v)
+		if err != nil {
+			return nil, err
+		}
+
+		rst := reflect.New(out).Elem()
+		given := map[string]bool{}
+		for _, e := range entries {
+			f, ok := lookupField(fields, e.key)
+			if !ok {
+				return nil, fmt.Errorf("unknown key %q", e.key)
+			}
+
+			u := rst
+			for _, i := range f.index {
+				if u.Kind() == reflect.Pointer {
+					if u.IsNil() {
+						u.Set(reflect.New(u.Type().Elem()))
+					}
+
+					u = u.Elem()
+				}
+
+				u = u.Field(i)
+			}
+
+			elem, err := m.elem(u.Type(), e.value.Type(), strict)
+			if err != nil {
+				return nil, fmt.Errorf("[%s]: %w", e.key, err)
+			}
+
+			w, err := elem(e.value)
+			if err != nil {
+				return nil, fmt.Errorf("[%s]: %w", e.key, err)
+			}
+
+			u.Set(w)
+			given[f.name] = true
+		}
+
+		for _, f := range fields {
+			if f.required && !given[f.name] {
+				return nil, fmt.Errorf("missing required key %q", f.name)
+			}
+		}
+
+		return rst.Interface(), nil
+	}
+}
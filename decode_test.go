@@ -0,0 +1,177 @@
+package pl_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/pl"
+	"github.com/stretchr/testify/require"
+)
+
+type Meta struct {
+	Name   string `pl:"name,required"`
+	Labels map[string]string
+}
+
+type ServiceSpec struct {
+	Meta
+	Image    string   `json:"image"`
+	Replicas int      `yaml:"replicas"`
+	Ports    []uint16 `json:"ports,omitempty"`
+	Timeout  *int     `pl:"timeout" json:"timeout_ms"`
+	Ignored  string   `json:"-"`
+}
+
+func TestConvMapDecodeStruct(t *testing.T) {
+	spec_t := reflect.TypeOf(ServiceSpec{})
+
+	convs := pl.NewConvMap()
+
+	timeout := 30
+	tcs := []struct {
+		desc     string
+		in       any
+		expected any
+	}{
+		{
+			desc: "map",
+			in: map[string]any{
+				"name":     "web",
+				"image":    "nginx",
+				"replicas": "3",
+				"ports":    []any{80, "443"},
+				"timeout":  30,
+			},
+			expected: ServiceSpec{
+				Meta:     Meta{Name: "web"},
+				Image:    "nginx",
+				Replicas: 3,
+				Ports:    []uint16{80, 443},
+				Timeout:  &timeout,
+			},
+		},
+		{
+			desc: "nested map",
+			in: map[string]any{
+				"Name":   "web",
+				"labels": map[string]any{"app": "web"},
+			},
+			expected: ServiceSpec{Meta: Meta{Name: "web", Labels: map[string]string{"app": "web"}}},
+		},
+		{
+			desc:     "map with keys of interface",
+			in:       map[any]any{"name": "web", "replicas": 1},
+			expected: ServiceSpec{Meta: Meta{Name: "web"}, Replicas: 1},
+		},
+		{
+			desc:     "pointer to struct",
+			in:       map[string]string{"name": "web"},
+			expected: &ServiceSpec{Meta: Meta{Name: "web"}},
+		},
+		{
+			desc:     "struct",
+			in:       struct{ Name, Image string }{Name: "web", Image: "nginx"},
+			expected: ServiceSpec{Meta: Meta{Name: "web"}, Image: "nginx"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			v, err := convs.ConvertTo(reflect.TypeOf(tc.expected), tc.in)
+			require.NoError(err)
+			require.Equal(tc.expected, v)
+		})
+	}
+
+	t.Run("struct that embeds itself", func(t *testing.T) {
+		require := require.New(t)
+
+		type Node struct {
+			*Node
+			X int
+		}
+
+		v, err := convs.ConvertTo(reflect.TypeOf(Node{}), map[string]any{"X": 1})
+		require.NoError(err)
+		require.Equal(Node{X: 1}, v)
+
+		v, err = convs.ConvertTo(reflect.TypeOf(Node{}), struct{ X int }{X: 2})
+		require.NoError(err)
+		require.Equal(Node{X: 2}, v)
+	})
+
+	t.Run("struct that has a field not in the type is not decoded", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := convs.ConvertTo(reflect.TypeOf(Meta{}), struct{ Name, Image string }{})
+		require.ErrorIs(err, pl.ErrNotFound)
+	})
+
+	t.Run("struct without exported fields is not decoded", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := convs.ConvertTo(reflect.TypeOf(Meta{}), time.Time{})
+		require.ErrorIs(err, pl.ErrNotFound)
+
+		_, err = convs.ConvertTo(reflect.TypeOf(Meta{}), &struct{ n int }{})
+		require.ErrorIs(err, pl.ErrNotFound)
+	})
+
+	t.Run("type implementing json.Unmarshaler is not decoded", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := convs.ConvertTo(reflect.TypeOf(time.Time{}), map[string]any{"wall": 0})
+		require.ErrorContains(err, "Time.UnmarshalJSON")
+	})
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			in   any
+			msgs []string
+		}{
+			{
+				desc: "key is unknown",
+				in:   map[string]any{"name": "web", "image": "nginx", "command": "run"},
+				msgs: []string{`unknown key "command"`},
+			},
+			{
+				desc: "key of skipped field is given",
+				in:   map[string]any{"name": "web", "Ignored": "x"},
+				msgs: []string{`unknown key "Ignored"`},
+			},
+			{
+				desc: "required key is not given",
+				in:   map[string]any{"image": "nginx"},
+				msgs: []string{`missing required key "name"`},
+			},
+			{
+				desc: "value cannot be converted",
+				in:   map[string]any{"name": "web", "replicas": "many"},
+				msgs: []string{`[replicas]: convert to int from string: strconv.ParseInt: parsing "many": invalid syntax`},
+			},
+			{
+				desc: "element of value cannot be converted",
+				in:   map[string]any{"name": "web", "ports": []any{80, 70000}},
+				msgs: []string{"[ports]: convert to []uint16 from []interface {}: [1]: convert to uint16 from int: 70000 overflows uint16"},
+			},
+			{
+				desc: "key is not a string",
+				in:   map[any]any{"name": "web", 1: "x"},
+				msgs: []string{"key 1 is not a string"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := convs.ConvertTo(spec_t, tc.in)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
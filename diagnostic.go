@@ -0,0 +1,80 @@
+package pl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// FormatDiagnostic renders the error with the line of the expression where the error occurred,
+// marking the failed span with a caret and an underline:
+//
+//	1:10: fn[1] Slurm: function "Slurm" not defined
+//	(sum 1 | Slurm)
+//	         ^~~~~
+//
+// It supports errors from ParseString and *ExecError.
+// If the error does not have a position, only the message is returned.
+func FormatDiagnostic(expr string, err error) string {
+	var (
+		msg string
+		pos lexer.Position
+		end lexer.Position
+	)
+
+	var (
+		exec_err  *ExecError
+		parse_err participle.Error
+	)
+	if errors.As(err, &exec_err) {
+		msg = exec_err.Error()
+		pos, end = exec_err.Pos, exec_err.EndPos
+	} else if errors.As(err, &parse_err) {
+		msg = parse_err.Message()
+		pos, end = parse_err.Position(), parse_err.Position()
+	} else {
+		return err.Error()
+	}
+
+	if pos.Line == 0 || pos.Offset > len(expr) {
+		return msg
+	}
+
+	line_begin := strings.LastIndexByte(expr[:pos.Offset], '\n') + 1
+	line_end := len(expr)
+	if i := strings.IndexByte(expr[pos.Offset:], '\n'); i >= 0 {
+		line_end = pos.Offset + i
+	}
+
+	// The span ends at the following token so trailing spaces are trimmed.
+	span_end := end.Offset
+	if span_end < pos.Offset {
+		span_end = pos.Offset
+	} else if span_end > line_end {
+		span_end = line_end
+	}
+
+	span := strings.TrimRight(expr[pos.Offset:span_end], " \t\r")
+	width := utf8.RuneCountInString(span)
+	if width == 0 {
+		width = 1
+	}
+
+	// Keep tabs so the caret is aligned as the line is.
+	padding := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, expr[line_begin:pos.Offset])
+
+	return fmt.Sprintf("%d:%d: %s\n%s\n%s^%s",
+		pos.Line, pos.Column, msg,
+		expr[line_begin:line_end],
+		padding, strings.Repeat("~", width-1),
+	)
+}
@@ -0,0 +1,81 @@
+package pl_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lesomnus/pl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDiagnostic(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["sum"] = func(vs ...int) int { return 0 }
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected string
+	}{
+		{
+			desc: "undefined function",
+			expr: `(sum 1 | Slurm 42)`,
+			expected: "" +
+				"1:10: fn[1] Slurm: function \"Slurm\" not defined\n" +
+				"(sum 1 | Slurm 42)\n" +
+				"         ^~~~~",
+		},
+		{
+			desc: "failed argument",
+			expr: `(sum 1  "Rick"  2)`,
+			expected: "" +
+				"1:9: fn[0] sum: arg[1]: convert to int from string: strconv.ParseInt: parsing \"Rick\": invalid syntax\n" +
+				"(sum 1  \"Rick\"  2)\n" +
+				"        ^~~~~~",
+		},
+		{
+			desc: "failed reference",
+			expr: `(sum 1 $.rick.morty)`,
+			expected: "" +
+				"1:8: fn[0] sum: arg[1]: reference: $ has no key rick: reference not found\n" +
+				"(sum 1 $.rick.morty)\n" +
+				"       ^~~~~~~~~~~~",
+		},
+		{
+			desc: "failed nested function in multiple lines",
+			expr: "(sum 1\n\t| sum (sum\n\t\t(sum \"Summer\") ) )",
+			expected: "" +
+				"3:8: fn[1] sum: arg[0]: fn[0] sum: arg[0]: fn[0] sum: arg[0]: convert to int from string: strconv.ParseInt: parsing \"Summer\": invalid syntax\n" +
+				"\t\t(sum \"Summer\") ) )\n" +
+				"\t\t     ^~~~~~~~",
+		},
+		{
+			desc: "parse error",
+			expr: `(sum 1 & 2)`,
+			expected: "" +
+				"1:8: unexpected token \"&\" (expected \")\")\n" +
+				"(sum 1 & 2)\n" +
+				"       ^",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			_, err := executor.ExecuteExpr(tc.expr, map[string]any{})
+			require.Error(err)
+			require.Equal(tc.expected, pl.FormatDiagnostic(tc.expr, err))
+		})
+	}
+
+	t.Run("error without position", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := executor.Execute(pl.NewPl(must(pl.NewFn("Slurm"))), nil)
+		require.Error(err)
+		require.Equal(err.Error(), pl.FormatDiagnostic("(Slurm)", err))
+
+		err = errors.New("Jerry")
+		require.Equal("Jerry", pl.FormatDiagnostic("(Slurm)", err))
+	})
+}
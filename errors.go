@@ -0,0 +1,108 @@
+package pl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+var (
+	ErrUndefinedFunc = errors.New("not defined")
+	ErrArity         = errors.New("wrong number of arguments")
+	ErrConversion    = errors.New("conversion failed")
+	ErrRefNotFound   = errors.New("reference not found")
+)
+
+// Frame locates a function in a pipeline.
+type Frame struct {
+	Fn   int    // Index of the function in the pipeline.
+	Name string // Name of the function.
+	Arg  int    // Index of the argument of the function or -1 if it is not related to an argument.
+}
+
+// ExecError is an error occurred while compiling or executing a pipeline.
+type ExecError struct {
+	// Path is a chain of frames from the outermost pipeline to the failed function.
+	// Frames other than the last one denote the argument where the nested pipeline is.
+	Path []Frame
+
+	// Name is the name of the failed function.
+	Name string
+
+	// Arg is the index of the argument in the expression that caused the error,
+	// or -1 if the error is not related to an argument.
+	// Values passed from the previous function are regarded as the placeholder argument,
+	// or an argument following the explicit ones if there is no placeholder.
+	Arg int
+
+	// Ref is the reference that failed to be resolved.
+	Ref Ref
+
+	// Pos and EndPos are the span of the failed argument or function in the expression.
+	// EndPos is the position of the token following the span.
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Err error
+}
+
+func (e *ExecError) Error() string {
+	b := strings.Builder{}
+	for _, f := range e.Path {
+		fmt.Fprintf(&b, "fn[%d] %s: ", f.Fn, f.Name)
+		if f.Arg >= 0 {
+			fmt.Fprintf(&b, "arg[%d]: ", f.Arg)
+		}
+	}
+	if e.Ref != nil {
+		b.WriteString("reference: ")
+	}
+
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// conversionError is an error occurred while converting an argument into the parameter type.
+type conversionError struct {
+	out reflect.Type
+	in  reflect.Type // nil if the argument is nil.
+	err error
+}
+
+func (e *conversionError) Error() string {
+	in := "nil"
+	if e.in != nil {
+		in = e.in.String()
+	}
+
+	return fmt.Sprintf("convert to %s from %s: %s", e.out.String(), in, e.err.Error())
+}
+
+func (e *conversionError) Is(target error) bool {
+	return target == ErrConversion
+}
+
+func (e *conversionError) Unwrap() error {
+	return e.err
+}
+
+// argError is an error caused by i-th argument given to the function.
+type argError struct {
+	index int
+	err   error
+}
+
+func (e *argError) Error() string {
+	return fmt.Sprintf("arg[%d]: %s", e.index, e.err.Error())
+}
+
+func (e *argError) Unwrap() error {
+	return e.err
+}
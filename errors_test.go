@@ -0,0 +1,117 @@
+package pl_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lesomnus/pl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecError(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["sum"] = func(vs ...int) int {
+		rst := 0
+		for _, v := range vs {
+			rst += v
+		}
+
+		return rst
+	}
+	executor.Funcs["two"] = func(lhs int, rhs int) int { return lhs + rhs }
+
+	tcs := []struct {
+		desc   string
+		expr   string
+		target error
+		path   []pl.Frame
+		arg    int
+		column int
+	}{
+		{
+			desc:   "function is not defined",
+			expr:   `(sum 1 | Slurm)`,
+			target: pl.ErrUndefinedFunc,
+			path:   []pl.Frame{{Fn: 1, Name: "Slurm", Arg: -1}},
+			arg:    -1,
+			column: 10,
+		},
+		{
+			desc:   "nested function has wrong number of arguments",
+			expr:   `(sum 1 (two 1 2 3))`,
+			target: pl.ErrArity,
+			path:   []pl.Frame{{Fn: 0, Name: "sum", Arg: 1}, {Fn: 0, Name: "two", Arg: -1}},
+			arg:    -1,
+			column: 9,
+		},
+		{
+			desc:   "constant cannot be converted",
+			expr:   `(sum 1 "Rick")`,
+			target: pl.ErrConversion,
+			path:   []pl.Frame{{Fn: 0, Name: "sum", Arg: 1}},
+			arg:    1,
+			column: 8,
+		},
+		{
+			desc:   "result of nested pipeline cannot be converted",
+			expr:   `(sum 1 (pass 2 "Rick") 3)`,
+			target: pl.ErrConversion,
+			path:   []pl.Frame{{Fn: 0, Name: "sum", Arg: 1}},
+			arg:    1,
+			column: 8,
+		},
+		{
+			desc:   "value from the previous function cannot be converted",
+			expr:   `(pass "Rick" | sum 1 2)`,
+			target: pl.ErrConversion,
+			path:   []pl.Frame{{Fn: 1, Name: "sum", Arg: 2}},
+			arg:    2,
+			column: 16,
+		},
+		{
+			desc:   "reference is not found",
+			expr:   `(sum 1 $.morty)`,
+			target: pl.ErrRefNotFound,
+			path:   []pl.Frame{{Fn: 0, Name: "sum", Arg: 1}},
+			arg:    1,
+			column: 8,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			_, err := executor.ExecuteExpr(tc.expr, map[string]int{"rick": 42})
+			require.ErrorIs(err, tc.target)
+
+			var exec_err *pl.ExecError
+			require.True(errors.As(err, &exec_err))
+			require.Equal(tc.path, exec_err.Path)
+			require.Equal(tc.path[len(tc.path)-1].Name, exec_err.Name)
+			require.Equal(tc.arg, exec_err.Arg)
+			require.Equal(tc.column, exec_err.Pos.Column)
+		})
+	}
+
+	t.Run("has failed reference", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := executor.ExecuteExpr(`(sum (sum $.rick.morty))`, map[string]int{"rick": 42})
+
+		var exec_err *pl.ExecError
+		require.True(errors.As(err, &exec_err))
+		require.Equal(".rick.morty", exec_err.Ref.String())
+		require.Equal("fn[0] sum: arg[0]: fn[0] sum: arg[0]: reference: $.rick is not an object but int", err.Error())
+	})
+
+	t.Run("wraps an error returned by the function", func(t *testing.T) {
+		require := require.New(t)
+
+		cause := errors.New("Jerry")
+		executor := pl.Executor{Funcs: pl.FuncMap{"fail": func() (int, error) { return 0, cause }}}
+
+		_, err := executor.ExecuteExpr(`(fail)`, nil)
+		require.ErrorIs(err, cause)
+		require.Equal("fn[0] fail: Jerry", err.Error())
+	})
+}
@@ -1,6 +1,8 @@
 package pl_test
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/lesomnus/pl"
@@ -27,3 +29,102 @@ func TestExample(t *testing.T) {
 	require.True(ok)
 	require.Equal(93, v)
 }
+
+func ExampleExecutor_ExecuteExpr_as() {
+	executor := pl.NewExecutor()
+
+	rst, err := executor.ExecuteExpr(`(regex "^v(?P<major>\\d+)" $.tag | as $m | printf "%s.0.0 from %s" $m.ByName.major)`, map[string]any{"tag": "v12"})
+	fmt.Println(rst, err)
+	// Output: [12.0.0 from v12] <nil>
+}
+
+func ExampleExecutor_ExecuteExpr_lambda() {
+	executor := pl.NewExecutor()
+	executor.Funcs["sum"] = func(vs ...int) int {
+		rst := 0
+		for _, v := range vs {
+			rst += v
+		}
+
+		return rst
+	}
+
+	data := map[string]any{"tag0": "v2", "tag1": "v1"}
+	for _, expr := range []string{
+		`(regex "^v(?P<major>\\d+)" $.tag0 $.tag1 | sort_by {m -> pass $m.ByName.major} | map {m -> printf "%s" $m})`,
+		`(pass 1 2 3 | reduce {acc, x -> sum $acc $x} 0)`,
+	} {
+		rst, err := executor.ExecuteExpr(expr, data)
+		fmt.Println(rst, err)
+	}
+	// Output:
+	// [v1 v2] <nil>
+	// [6] <nil>
+}
+
+func ExampleThunk() {
+	executor := pl.NewExecutor()
+	executor.Funcs["retry"] = func(n int, f pl.Thunk) (any, error) {
+		var err error
+		for i := 0; i < n; i++ {
+			var vs []any
+			if vs, err = f(); err == nil {
+				return pl.Many(vs...), nil
+			}
+		}
+
+		return nil, err
+	}
+
+	calls := 0
+	executor.Funcs["flaky"] = func() (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errors.New("failed")
+		}
+
+		return "ok", nil
+	}
+
+	rst, err := executor.ExecuteExpr(`(retry 3 (flaky))`, nil)
+	fmt.Println(rst, err, calls)
+	// Output: [ok] <nil> 3
+}
+
+func ExampleExecutor_ExecuteExpr_references() {
+	executor := pl.NewExecutor()
+
+	data := map[string]any{
+		"tag": "",
+		"services": map[string]any{
+			"web":   map[string]any{"image": "nginx", "enabled": true},
+			"cache": map[string]any{"image": "redis"},
+		},
+		"items": []any{
+			map[string]any{"kind": "Deployment"},
+			map[string]any{"kind": "Service"},
+		},
+	}
+	for _, expr := range []string{
+		`(pass "Rick" | printf "%s and %s" _ "Morty")`,
+		`(pass $.tag | default "latest")`,
+		`(default "latest" $.image?.tag)`,
+		`(printf "%s %s" $.services[*].image)`,
+		`(pass $..image)`,
+		`(pass $.services[?(.enabled)].image)`,
+		`(pass $.items[?(eq $.kind "Deployment")])`,
+		`(let $kind "Service" | pass $.items[?(eq $kind $.kind)])`,
+	} {
+		rst, err := executor.ExecuteExpr(expr, data)
+		fmt.Println(rst, err)
+	}
+	// Output:
+	// [Rick and Morty] <nil>
+	// [latest] <nil>
+	// [latest] <nil>
+	// [redis nginx] <nil>
+	// [redis nginx] <nil>
+	// [nginx] <nil>
+	// [map[kind:Deployment]] <nil>
+	// [map[kind:Service]] <nil>
+}
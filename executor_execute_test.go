@@ -1,7 +1,14 @@
 package pl_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/lesomnus/pl"
 	"github.com/stretchr/testify/require"
@@ -97,6 +104,11 @@ func TestExecutorExecute(t *testing.T) {
 				pl:   &pl.Pl{Funcs: []*pl.Fn{{Name: "sum", Args: []*pl.Arg{{Int: addr(42)}, {}}}}},
 				msgs: []string{"fn[0]", "sum", "arg[1]"},
 			},
+			{
+				desc: "nil is given to a parameter that cannot be nil",
+				pl:   &pl.Pl{Funcs: []*pl.Fn{must(pl.NewFn("sum", 42, nil))}},
+				msgs: []string{"fn[0]", "sum", "arg[1]", "convert to int from nil"},
+			},
 			{
 				desc: "nested function is failed when evaluate",
 				pl:   &pl.Pl{Funcs: []*pl.Fn{{Name: "sum", Args: must(pl.NewArgs(42, &pl.Pl{Funcs: []*pl.Fn{{Name: "Jerry Smith (C-131)", Args: []*pl.Arg{}}}}))}}},
@@ -147,3 +159,1358 @@ func TestExecutorExecuteExpr(t *testing.T) {
 		require.ErrorContains(err, "unexpected token")
 	})
 }
+
+func TestExecutorExecuteLiterals(t *testing.T) {
+	executor := pl.Executor{
+		Funcs: map[string]any{
+			"not": func(v bool) bool { return !v },
+			"describe": func(p *int, i any, s []int, m map[string]int) string {
+				return fmt.Sprintf("%v %v %v %v", p == nil, i == nil, s == nil, m == nil)
+			},
+			"pass": func(vs ...any) []any { return vs },
+		},
+	}
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "boolean",
+			expr:     `(not true | not | not)`,
+			expected: []any{false},
+		},
+		{
+			desc:     "nil to nillable parameters",
+			expr:     `(describe nil nil nil nil)`,
+			expected: []any{"true true true true"},
+		},
+		{
+			desc:     "nil is piped",
+			expr:     `(pass nil | pass)`,
+			expected: []any{nil},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, nil)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+}
+
+func TestExecutorExecuteConversionChain(t *testing.T) {
+	type Celsius float64
+	type Kelvin float64
+
+	require := require.New(t)
+
+	executor := pl.NewExecutor()
+	executor.Funcs["kelvin"] = func(v Kelvin) string { return fmt.Sprintf("%.2fK", v) }
+	executor.Convs.Set(reflect.TypeOf(0.0), reflect.TypeOf(Celsius(0)), func(v reflect.Value) (any, error) {
+		return Celsius(v.Float()), nil
+	})
+	executor.Convs.Set(reflect.TypeOf(Celsius(0)), reflect.TypeOf(Kelvin(0)), func(v reflect.Value) (any, error) {
+		return Kelvin(v.Float() + 273.15), nil
+	})
+
+	// int -> float64 -> Celsius -> Kelvin
+	rst, err := executor.ExecuteExpr(`(kelvin 20)`, nil)
+	require.NoError(err)
+	require.Equal([]any{"293.15K"}, rst)
+
+	_, err = executor.ExecuteExpr(`(kelvin [1])`, nil)
+	require.ErrorIs(err, pl.ErrNotFound)
+	require.ErrorContains(err, "convert to pl_test.Kelvin from []interface {}")
+}
+
+func TestExecutorExecuteCompositeConversion(t *testing.T) {
+	type Config struct{ Name string }
+
+	executor := pl.NewExecutor()
+	executor.Funcs["sum"] = func(vs []int) int {
+		rst := 0
+		for _, v := range vs {
+			rst += v
+		}
+
+		return rst
+	}
+	executor.Funcs["name"] = func(c *Config) string { return c.Name }
+	executor.Funcs["deploy"] = func(s ServiceSpec) string { return fmt.Sprintf("%s:%s x%d", s.Name, s.Image, s.Replicas) }
+
+	data := map[string]any{
+		"ports":   []any{80, "443"},
+		"config":  Config{Name: "Rick"},
+		"service": map[string]any{"name": "web", "image": "nginx", "replicas": 2},
+	}
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "elements of reference",
+			expr:     `(sum $.ports)`,
+			expected: []any{523},
+		},
+		{
+			desc:     "elements of literal",
+			expr:     `(sum [1, "2", 3.0])`,
+			expected: []any{6},
+		},
+		{
+			desc:     "pointer to value",
+			expr:     `(name $.config)`,
+			expected: []any{"Rick"},
+		},
+		{
+			desc:     "map into struct",
+			expr:     `(deploy $.service)`,
+			expected: []any{"web:nginx x2"},
+		},
+		{
+			desc:     "map literal into struct",
+			expr:     `(deploy {name: "db", image: "redis"})`,
+			expected: []any{"db:redis x0"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, data)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("fails if element cannot be converted", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := executor.ExecuteExpr(`(sum [1, "Rick"])`, nil)
+		require.ErrorIs(err, pl.ErrConversion)
+		require.ErrorContains(err, "fn[0] sum: arg[0]: convert to []int from []interface {}: [1]: convert to int from string")
+	})
+
+	t.Run("struct that embeds itself", func(t *testing.T) {
+		require := require.New(t)
+
+		type Node struct {
+			*Node
+			X int
+		}
+
+		executor := pl.NewExecutor()
+		executor.Funcs["n"] = func(v Node) int { return v.X }
+
+		rst, err := executor.ExecuteExpr(`(n {X: 1})`, nil)
+		require.NoError(err)
+		require.Equal([]any{1}, rst)
+	})
+
+	t.Run("fails if key is unknown", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := executor.ExecuteExpr(`(deploy {name: "db", tag: "latest"})`, nil)
+		require.ErrorContains(err, `fn[0] deploy: arg[0]: convert to pl_test.ServiceSpec from map[string]interface {}: unknown key "tag"`)
+	})
+}
+
+func TestExecutorExecuteDefaultConversions(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["f32"] = func(v float32) float32 { return v }
+	executor.Funcs["i"] = func(v int) int { return v }
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "float literal to float32",
+			expr:     `(f32 0.1)`,
+			expected: []any{float32(0.1)},
+		},
+		{
+			desc:     "float from data to float32",
+			expr:     `(f32 $.f)`,
+			expected: []any{float32(2.5)},
+		},
+		{
+			desc:     "string with leading zero to int",
+			expr:     `(i $.z)`,
+			expected: []any{10},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, map[string]any{"f": 2.5, "z": "010"})
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			expr string
+			msgs []string
+		}{
+			{
+				desc: "float64 loses precision in float32",
+				expr: `(f32 3.14159265)`,
+				msgs: []string{"fn[0]", "f32", "arg[0]", "convert to float32 from float64", "cannot be represented"},
+			},
+			{
+				desc: "float64 overflows float32",
+				expr: `(f32 1e300)`,
+				msgs: []string{"fn[0]", "f32", "arg[0]", "overflows float32"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := executor.ExecuteExpr(tc.expr, nil)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestExecutorExecuteStrict(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Strict = true
+	executor.Funcs["int"] = func(v int) int { return v }
+	executor.Funcs["int32"] = func(v int32) int32 { return v }
+	executor.Funcs["int64"] = func(v int64) int64 { return v }
+	executor.Funcs["float64"] = func(v float64) float64 { return v }
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "widening conversion",
+			expr:     `(int64 42)`,
+			expected: []any{int64(42)},
+		},
+		{
+			desc:     "conversion from string",
+			expr:     `(int "42")`,
+			expected: []any{42},
+		},
+		{
+			desc:     "int32 to float64",
+			expr:     `(int32 "42" | float64)`,
+			expected: []any{42.0},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, nil)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			expr string
+			msgs []string
+		}{
+			{
+				desc: "float64 to int",
+				expr: `(int 3.0)`,
+				msgs: []string{"fn[0]", "int", "arg[0]", "convert to int from float64", "lossy"},
+			},
+			{
+				desc: "int to int32",
+				expr: `(int 1 | int32)`,
+				msgs: []string{"fn[1]", "int32", "arg[0]", "convert to int32 from int", "lossy"},
+			},
+			{
+				desc: "int to float64",
+				expr: `(float64 1)`,
+				msgs: []string{"fn[0]", "float64", "arg[0]", "convert to float64 from int", "lossy"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := executor.ExecuteExpr(tc.expr, nil)
+				require.ErrorIs(err, pl.ErrLossy)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestExecutorExecuteCollections(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["twice"] = func(vs ...int) []int {
+		for i, v := range vs {
+			vs[i] = v * 2
+		}
+
+		return vs
+	}
+	executor.Funcs["join"] = func(vs []string) string { return strings.Join(vs, ",") }
+	executor.Convs.Set(reflect.TypeOf([]any{}), reflect.TypeOf([]string{}), func(v reflect.Value) (any, error) {
+		rst := []string{}
+		for _, item := range v.Interface().([]any) {
+			rst = append(rst, fmt.Sprint(item))
+		}
+
+		return rst, nil
+	})
+
+	data := map[string]any{"a": "Rick"}
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "list",
+			expr:     `(pass [1, "b", $.a, nil, [], {}])`,
+			expected: []any{[]any{1, "b", "Rick", nil, []any{}, map[string]any{}}},
+		},
+		{
+			desc:     "results of nested pipeline are spread in list",
+			expr:     `(pass [(twice 1 2), (twice 3)])`,
+			expected: []any{[]any{2, 4, 6}},
+		},
+		{
+			desc: "map",
+			expr: `(pass {a: $.a, "b-c": (twice 1 2), d: (twice 3), e: [$.a]})`,
+			expected: []any{map[string]any{
+				"a":   "Rick",
+				"b-c": []any{2, 4},
+				"d":   6,
+				"e":   []any{"Rick"},
+			}},
+		},
+		{
+			desc:     "converted into parameter type",
+			expr:     `(join [1, $.a, 3.14])`,
+			expected: []any{"1,Rick,3.14"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, data)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("literal is not modified by previous run", func(t *testing.T) {
+		require := require.New(t)
+
+		executor := pl.NewExecutor()
+		executor.Funcs["push"] = func(vs []any) []any {
+			vs[0] = "Morty"
+			return append(vs, "Summer")
+		}
+
+		prog, err := executor.Compile(must(pl.ParseString(`(push ["Rick"])`)))
+		require.NoError(err)
+
+		for i := 0; i < 2; i++ {
+			rst, err := prog.Run(nil)
+			require.NoError(err)
+			require.Equal([]any{"Morty", "Summer"}, rst)
+		}
+	})
+
+	t.Run("reference in literal is not found", func(t *testing.T) {
+		require := require.New(t)
+
+		expr := `(pass 1 [2, {a: $.b}])`
+		_, err := executor.ExecuteExpr(expr, data)
+		require.ErrorIs(err, pl.ErrRefNotFound)
+
+		exec_err := &pl.ExecError{}
+		require.ErrorAs(err, &exec_err)
+		require.Equal(1, exec_err.Arg)
+		require.Equal("$.b", expr[exec_err.Pos.Offset:exec_err.EndPos.Offset])
+	})
+}
+
+func TestExecutorExecutePlaceholder(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["add"] = func(lhs int, rhs int) int { return lhs + rhs }
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "results are appended without placeholder",
+			expr:     `(pass "Rick" | printf "%s and %s" "Morty")`,
+			expected: []any{"Morty and Rick"},
+		},
+		{
+			desc:     "results are spliced at placeholder",
+			expr:     `(pass "Rick" | printf "%s and %s" _ "Morty")`,
+			expected: []any{"Rick and Morty"},
+		},
+		{
+			desc:     "multiple results are spread",
+			expr:     `(pass "%s-%s" "Rick" | printf _ "Morty")`,
+			expected: []any{"Rick-Morty"},
+		},
+		{
+			desc:     "multiple placeholders",
+			expr:     `(pass 2 | add _ _)`,
+			expected: []any{4},
+		},
+		{
+			desc:     "placeholder in literal",
+			expr:     `(pass 1 2 | pass [0, _, 3] {a: _})`,
+			expected: []any{[]any{0, 1, 2, 3}, map[string]any{"a": []any{1, 2}}},
+		},
+		{
+			desc:     "placeholder in nested pipeline",
+			expr:     `(pass 1 | add _ (pass 2 | add _ 3))`,
+			expected: []any{1 + 2 + 3},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, nil)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			expr string
+			msgs []string
+		}{
+			{
+				desc: "placeholder is used in the first function",
+				expr: `(add _ 1)`,
+				msgs: []string{"fn[0]", "add", "arg[0]", "placeholder"},
+			},
+			{
+				desc: "placeholder is used in the first function of nested pipeline",
+				expr: `(pass 1 | add _ (pass [_]))`,
+				msgs: []string{"fn[1]", "arg[1]", "fn[0]", "pass", "arg[0]", "placeholder"},
+			},
+			{
+				desc: "value at placeholder cannot be converted",
+				expr: `(pass "Rick" | add 1 _)`,
+				msgs: []string{"fn[1]", "add", "arg[1]", "convert to int from string"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := executor.ExecuteExpr(tc.expr, nil)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestExecutorExecuteSpread(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["bytes"] = func(s string) []byte { return []byte(s) }
+	executor.Funcs["bytes_single"] = func(s string) any { return pl.Single([]byte(s)) }
+	executor.Funcs["bytes_no_spread"] = pl.NoSpread(func(s string) []byte { return []byte(s) })
+	executor.Funcs["pair"] = func(s string) any { return pl.Many(s, len(s)) }
+	executor.Funcs["len"] = func(vs ...any) int { return len(vs) }
+	executor.Funcs["id"] = func(v any) any { return v }
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "slice is spread",
+			expr:     `(bytes "Rick" | len)`,
+			expected: []any{4},
+		},
+		{
+			desc:     "slice wrapped by Single is not spread",
+			expr:     `(bytes_single "Rick" | len)`,
+			expected: []any{1},
+		},
+		{
+			desc:     "result of NoSpread function is not spread",
+			expr:     `(bytes_no_spread "Rick" | len)`,
+			expected: []any{1},
+		},
+		{
+			desc:     "values wrapped by Many are spread",
+			expr:     `(pair "Rick" | len)`,
+			expected: []any{2},
+		},
+		{
+			desc:     "nested pipeline",
+			expr:     `(len (bytes_single "Rick") (pair "Morty"))`,
+			expected: []any{3},
+		},
+		{
+			desc:     "last result is unwrapped",
+			expr:     `(bytes_single "Rick")`,
+			expected: []any{[]byte("Rick")},
+		},
+		{
+			desc:     "slice of reference is spread",
+			expr:     `(id $.tags[1:] | printf "%s-%s")`,
+			expected: []any{"v2-v3"},
+		},
+		{
+			desc:     "values of wildcard are spread",
+			expr:     `(printf "%s %s %s" $.services[*].image)`,
+			expected: []any{"redis nginx nginx"},
+		},
+		{
+			desc:     "values of recursive descent are spread",
+			expr:     `(len $..image 0)`,
+			expected: []any{4},
+		},
+		{
+			desc:     "values of wildcard are spread in list",
+			expr:     `(id [$.tags[*], "v4"] | len)`,
+			expected: []any{4},
+		},
+		{
+			desc:     "values of wildcard are a slice in map",
+			expr:     `(id {tags: $.tags[*]} | len)`,
+			expected: []any{1},
+		},
+		{
+			desc:     "negative index and slice of string",
+			expr:     `(printf "%s%s" $.tags[-1] $.name[:1])`,
+			expected: []any{"v3R"},
+		},
+	}
+
+	data := map[string]any{
+		"name": "Rick",
+		"tags": []string{"v1", "v2", "v3"},
+		"services": map[string]any{
+			"web":   map[string]any{"image": "nginx"},
+			"cache": map[string]any{"image": "redis"},
+			"proxy": map[string]any{"image": "nginx"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, data)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+}
+
+func TestExecutorExecuteBind(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["add"] = func(lhs int, rhs int) int { return lhs + rhs }
+
+	data := map[string]any{
+		"x": 42,
+		"v": map[string]any{"ByName": map[string]any{"major": 1}},
+	}
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "let binds arguments",
+			expr:     `(let $x (add 1 2) | add $x $x)`,
+			expected: []any{6},
+		},
+		{
+			desc:     "let binds multiple values as slice",
+			expr:     `(let $x 1 (pass 2 3) | pass $x)`,
+			expected: []any{[]any{1, 2, 3}},
+		},
+		{
+			desc:     "let passes through results of previous function",
+			expr:     `(pass 1 | let $x 2 | add $x)`,
+			expected: []any{3},
+		},
+		{
+			desc:     "as binds results of previous function",
+			expr:     `(regex "^v(?P<major>\\d+)" "v1" | as $m | printf "%s-%s" $m.ByName.major)`,
+			expected: []any{"1-v1"},
+		},
+		{
+			desc:     "as passes through results of previous function",
+			expr:     `(add 1 2 | as $x | add $x)`,
+			expected: []any{6},
+		},
+		{
+			desc:     "variable with path",
+			expr:     `(let $v $.v | pass $v.ByName.major)`,
+			expected: []any{1},
+		},
+		{
+			desc:     "variable is visible in nested pipeline",
+			expr:     `(let $y 1 | add (add $y 2) (pass $y))`,
+			expected: []any{4},
+		},
+		{
+			desc:     "variable is shadowed",
+			expr:     `(let $y 1 | let $y (add $y 1) | pass $y)`,
+			expected: []any{2},
+		},
+		{
+			desc:     "variable bound in nested pipeline is not visible outside",
+			expr:     `(add (let $x 1 | pass $x) $x)`,
+			expected: []any{43},
+		},
+		{
+			desc:     "variable that is not bound refers to data",
+			expr:     `(pass $x $v.ByName.major)`,
+			expected: []any{42, 1},
+		},
+		{
+			desc:     "variable in literal",
+			expr:     `(let $y 1 | pass [$y, {a: $y}])`,
+			expected: []any{[]any{1, map[string]any{"a": 1}}},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, data)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			expr string
+			msgs []string
+		}{
+			{
+				desc: "variable name is not given",
+				expr: `(let 1 2)`,
+				msgs: []string{"fn[0]", "let", "variable name"},
+			},
+			{
+				desc: "variable name has path",
+				expr: `(pass 1 | as $x.a)`,
+				msgs: []string{"fn[1]", "as", "variable name"},
+			},
+			{
+				desc: "value is not given",
+				expr: `(let $x)`,
+				msgs: []string{"let", "requires a value"},
+			},
+			{
+				desc: "as is used in the first function",
+				expr: `(as $x)`,
+				msgs: []string{"fn[0]", "as", "first function"},
+			},
+			{
+				desc: "path from variable is not found",
+				expr: `(let $y {a: 1} | pass $y.b)`,
+				msgs: []string{"fn[1]", "pass", "arg[0]", "$y", "no key b"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := executor.ExecuteExpr(tc.expr, data)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestExecutorExecuteForm(t *testing.T) {
+	calls := []string{}
+	executor := pl.NewExecutor()
+	executor.Funcs["call"] = func(name string, vs ...any) any {
+		calls = append(calls, name)
+		if len(vs) == 0 {
+			return name
+		}
+
+		return pl.Many(vs...)
+	}
+	executor.Funcs["fail"] = func() (any, error) {
+		return nil, errors.New("failed")
+	}
+
+	data := map[string]any{
+		"override": "Rick",
+		"empty":    "",
+		"zero":     0,
+		"nil":      nil,
+		"list":     []int{1, 2},
+	}
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+		calls    []string
+	}{
+		{
+			desc:     "if true",
+			expr:     `(if true (call "then") (fail))`,
+			expected: []any{"then"},
+			calls:    []string{"then"},
+		},
+		{
+			desc:     "if false",
+			expr:     `(if $.empty (fail) (call "else"))`,
+			expected: []any{"else"},
+			calls:    []string{"else"},
+		},
+		{
+			desc:     "if false without else",
+			expr:     `(if $.zero (fail))`,
+			expected: []any{},
+		},
+		{
+			desc:     "results of branch are spread",
+			expr:     `(if true (call "then" 1 2) 3)`,
+			expected: []any{1, 2},
+			calls:    []string{"then"},
+		},
+		{
+			desc:     "condition is piped",
+			expr:     `(pass $.list | if _ "yes" "no")`,
+			expected: []any{"yes"},
+		},
+		{
+			desc:     "and gives first false value",
+			expr:     `(and 1 $.zero (fail))`,
+			expected: []any{0},
+		},
+		{
+			desc:     "and gives last value",
+			expr:     `(and 1 "Rick" (call "last"))`,
+			expected: []any{"last"},
+			calls:    []string{"last"},
+		},
+		{
+			desc:     "or gives first true value",
+			expr:     `(or $.nil "" (call "Morty") (fail))`,
+			expected: []any{"Morty"},
+			calls:    []string{"Morty"},
+		},
+		{
+			desc:     "or gives last value",
+			expr:     `(or false 0)`,
+			expected: []any{0},
+		},
+		{
+			desc:     "coalesce gives first non-nil value",
+			expr:     `(coalesce $.nil $.not_exist $.zero (fail))`,
+			expected: []any{0},
+		},
+		{
+			desc:     "coalesce gives nil if all values are nil",
+			expr:     `(coalesce nil $.nil)`,
+			expected: []any{nil},
+		},
+		{
+			desc:     "default gives value if it is true",
+			expr:     `(default (fail) $.override)`,
+			expected: []any{"Rick"},
+		},
+		{
+			desc:     "default gives default if value is not found",
+			expr:     `(default (call "default") $.not_exist)`,
+			expected: []any{"default"},
+			calls:    []string{"default"},
+		},
+		{
+			desc:     "optional reference gives nil if it is not found",
+			expr:     `(pass $.not_exist?.a $.nil?.a $.list[2]?)`,
+			expected: []any{nil, nil, nil},
+		},
+		{
+			desc:     "default gives default if optional reference is nil",
+			expr:     `(default "fallback" $.nil?.a)`,
+			expected: []any{"fallback"},
+		},
+		{
+			desc:     "value of default is piped",
+			expr:     `(pass $.empty | default "Morty")`,
+			expected: []any{"Morty"},
+		},
+		{
+			desc:     "special form in nested pipeline",
+			expr:     `(printf "%s-%s" (default "v" $.version) (or $.empty "latest"))`,
+			expected: []any{"v-latest"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			calls = []string{}
+			rst, err := executor.ExecuteExpr(tc.expr, data)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+			if tc.calls != nil {
+				require.Equal(tc.calls, calls)
+			}
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			expr string
+			msgs []string
+		}{
+			{
+				desc: "too few operands",
+				expr: `(if true)`,
+				msgs: []string{"fn[0]", "if", "at least 2 args"},
+			},
+			{
+				desc: "too many operands",
+				expr: `(default 1 2 3)`,
+				msgs: []string{"fn[0]", "default", "at most 2 args"},
+			},
+			{
+				desc: "too many operands with piped values",
+				expr: `(pass 1 2 | default 3)`,
+				msgs: []string{"fn[1]", "default", "at most 2 args"},
+			},
+			{
+				desc: "evaluated operand fails",
+				expr: `(or 0 (fail))`,
+				msgs: []string{"fn[0]", "or", "arg[1]", "failed"},
+			},
+			{
+				desc: "reference of condition is not found",
+				expr: `(if $.not_exist 1 2)`,
+				msgs: []string{"fn[0]", "if", "arg[0]", "not_exist"},
+			},
+			{
+				desc: "reference through nil is not optional",
+				expr: `(pass $.nil.a)`,
+				msgs: []string{"fn[0]", "pass", "arg[0]", "$.nil is nil"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := executor.ExecuteExpr(tc.expr, data)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestExecutorExecuteFilter(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["fail"] = func() (any, error) {
+		return nil, errors.New("failed")
+	}
+
+	data := map[string]any{
+		"services": map[string]any{
+			"web":   map[string]any{"name": "web", "enabled": true, "replicas": 3},
+			"cache": map[string]any{"name": "cache", "enabled": false, "replicas": 1},
+			"proxy": map[string]any{"name": "proxy", "replicas": 3},
+		},
+		"items": []any{
+			map[string]any{"kind": "Deployment", "name": "a"},
+			map[string]any{"kind": "Service", "name": "b"},
+			map[string]any{"kind": "Deployment", "name": "c"},
+		},
+		"groups": []any{
+			map[string]any{"name": "users", "members": []any{map[string]any{"admin": false}}},
+			map[string]any{"name": "admins", "members": []any{map[string]any{"admin": true}}},
+		},
+		"tags":  []any{"v1", "latest", "v2"},
+		"mixed": []any{1, map[string]any{"enabled": true}, []any{true}},
+	}
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "reference predicate",
+			expr:     `(pass $.services[?(.enabled)].name)`,
+			expected: []any{"web"},
+		},
+		{
+			desc:     "pipeline predicate",
+			expr:     `(pass $.items[?(eq $.kind "Deployment")].name)`,
+			expected: []any{"a", "c"},
+		},
+		{
+			desc:     "predicate of map values in order of keys",
+			expr:     `(pass $.services[?(eq $.replicas 3)].name)`,
+			expected: []any{"proxy", "web"},
+		},
+		{
+			desc:     "predicate with variable",
+			expr:     `(let $kind "Service" | pass $.items[?(eq $.kind $kind)].name)`,
+			expected: []any{"b"},
+		},
+		{
+			desc:     "predicate with variable before element",
+			expr:     `(let $kind "Service" | pass $.items[?(eq $kind $.kind)].name)`,
+			expected: []any{"b"},
+		},
+		{
+			desc:     "nested filters",
+			expr:     `(pass $.groups[?(.members[?(.admin)])].name)`,
+			expected: []any{"admins"},
+		},
+		{
+			desc:     "nothing is selected",
+			expr:     `(pass $.items[?(eq $.kind "Pod")])`,
+			expected: []any{},
+		},
+		{
+			desc:     "predicate with element itself",
+			expr:     `(pass $.tags[?(ne $ "latest")])`,
+			expected: []any{"v1", "v2"},
+		},
+		{
+			desc:     "reference predicate is false for element of other kind",
+			expr:     `(pass $.mixed[?(.enabled)])`,
+			expected: []any{map[string]any{"enabled": true}},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, data)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("resolve", func(t *testing.T) {
+		require := require.New(t)
+
+		p := must(pl.ParseString(`(pass $.items[?(ne $.name "b")].kind $.items[0])`))
+
+		v, err := executor.Resolve(data, p.Funcs[0].Args[0].Ref)
+		require.NoError(err)
+		require.Equal([]any{"Deployment", "Deployment"}, v)
+
+		v, err = executor.Resolve(data, p.Funcs[0].Args[1].Ref)
+		require.NoError(err)
+		require.Equal(data["items"].([]any)[0], v)
+	})
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			expr string
+			msgs []string
+		}{
+			{
+				desc: "function in predicate is not defined",
+				expr: `(pass $.items[?(foo)])`,
+				msgs: []string{"fn[0]", "pass", "arg[0]", "fn[0]", "foo", "not defined"},
+			},
+			{
+				desc: "predicate fails",
+				expr: `(pass 1 $.items[?(fail)])`,
+				msgs: []string{"fn[0]", "pass", "arg[1]", "fn[0]", "fail", "failed"},
+			},
+			{
+				desc: "reference in predicate does not start with $",
+				expr: `(pass $.items[?(eq .kind "Deployment")])`,
+				msgs: []string{`unexpected ".kind"; a reference starts with "$"`},
+			},
+			{
+				desc: "filter of non-list",
+				expr: `(pass $.items[0].kind[?(.a)])`,
+				msgs: []string{"fn[0]", "pass", "arg[0]", "not a list or a map"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := executor.ExecuteExpr(tc.expr, data)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestExecutorExecuteLambda(t *testing.T) {
+	executor := pl.NewExecutor()
+	executor.Funcs["add"] = func(lhs int, rhs int) int { return lhs + rhs }
+	executor.Funcs["gt"] = func(lhs int, rhs int) bool { return lhs > rhs }
+	executor.Funcs["len"] = func(s string) int { return len(s) }
+	executor.Funcs["atoi"] = strconv.Atoi
+
+	data := map[string]any{"min": 1}
+
+	tcs := []struct {
+		desc     string
+		expr     string
+		expected []any
+	}{
+		{
+			desc:     "map",
+			expr:     `(pass 1 2 3 | map {x -> add $x 1})`,
+			expected: []any{2, 3, 4},
+		},
+		{
+			desc:     "filter",
+			expr:     `(pass 1 2 3 | filter {x -> gt $x 1})`,
+			expected: []any{2, 3},
+		},
+		{
+			desc:     "reduce",
+			expr:     `(pass 1 2 3 | reduce {acc, x -> add $acc $x} 10)`,
+			expected: []any{16},
+		},
+		{
+			desc:     "sort_by",
+			expr:     `(pass "bb" "a" "ccc" | sort_by {s -> printf "%d" (len $s)})`,
+			expected: []any{"a", "bb", "ccc"},
+		},
+		{
+			desc:     "group_by",
+			expr:     `(pass 1 2 3 4 | group_by {x -> gt $x 2})`,
+			expected: []any{map[string][]any{"false": {1, 2}, "true": {3, 4}}},
+		},
+		{
+			desc:     "any all find",
+			expr:     `(pass (pass 1 2 3 | any {x -> gt $x 2}) (pass 1 2 3 | all {x -> gt $x 2}) (pass 1 2 3 | find {x -> gt $x 1}))`,
+			expected: []any{true, false, 2},
+		},
+		{
+			desc:     "lambda sees variables and data",
+			expr:     `(let $n 10 | pass 1 2 | map {x -> add $x $n | add $min})`,
+			expected: []any{12, 13},
+		},
+		{
+			desc:     "parameter shadows variable",
+			expr:     `(let $x 10 | pass 1 | map {x -> pass $x})`,
+			expected: []any{1},
+		},
+		{
+			desc: "select tags",
+			expr: `(pass "v1.2" "latest" "v1.10" "v0.9"
+				| regex "^v(?P<major>\\d+)\\.(?P<minor>\\d+)$" _
+				| sort_by {m -> atoi $m.ByName.minor}
+				| map {m -> printf "%s" $m})`,
+			expected: []any{"v1.2", "v0.9", "v1.10"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			rst, err := executor.ExecuteExpr(tc.expr, data)
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+		})
+	}
+
+	t.Run("fails if", func(t *testing.T) {
+		tcs := []struct {
+			desc string
+			expr string
+			msgs []string
+		}{
+			{
+				desc: "function in lambda is not defined",
+				expr: `(map {x -> Slurm $x} 1)`,
+				msgs: []string{"fn[0]", "map", "arg[0]", "Slurm", "not defined"},
+			},
+			{
+				desc: "function in lambda fails",
+				expr: `(map {x -> add $x "Rick"} 1)`,
+				msgs: []string{"fn[0] map: arg[0]: fn[0] add: arg[1]: convert to int from string"},
+			},
+			{
+				desc: "number of arguments does not match with parameters",
+				expr: `(map {acc, x -> add $acc $x} 1)`,
+				msgs: []string{"fn[0]", "map", "lambda takes 2 args but 1 args are given"},
+			},
+		}
+		for _, tc := range tcs {
+			t.Run(tc.desc, func(t *testing.T) {
+				require := require.New(t)
+
+				_, err := executor.ExecuteExpr(tc.expr, data)
+				for _, msg := range tc.msgs {
+					require.ErrorContains(err, msg)
+				}
+			})
+		}
+	})
+}
+
+func TestExecutorExecuteThunk(t *testing.T) {
+	num_calls := 0
+	executor := pl.NewExecutor()
+	executor.Funcs["flaky"] = func(n int) (int, error) {
+		num_calls++
+		if num_calls < n {
+			return 0, errors.New("flaky")
+		}
+
+		return num_calls, nil
+	}
+	executor.Funcs["fail"] = func() (any, error) {
+		num_calls++
+		return nil, errors.New("failed")
+	}
+	executor.Funcs["retry"] = func(n int, f pl.Thunk) (any, error) {
+		var err error
+		for i := 0; i < n; i++ {
+			var vs []any
+			if vs, err = f(); err == nil {
+				return pl.Many(vs...), nil
+			}
+		}
+
+		return nil, err
+	}
+	executor.Funcs["fallback"] = func(fs ...func() ([]any, error)) (any, error) {
+		var err error
+		for _, f := range fs {
+			var vs []any
+			if vs, err = f(); err == nil {
+				return pl.Many(vs...), nil
+			}
+		}
+
+		return nil, err
+	}
+	executor.Funcs["ignore"] = func(f pl.Thunk, v int) int { return v }
+
+	tcs := []struct {
+		desc      string
+		expr      string
+		expected  []any
+		num_calls int
+	}{
+		{
+			desc:      "thunk is called by function",
+			expr:      `(retry 5 (flaky 3))`,
+			expected:  []any{3},
+			num_calls: 3,
+		},
+		{
+			desc:      "thunk of variadic parameter",
+			expr:      `(fallback (fail) (pass 1 2) (fail))`,
+			expected:  []any{1, 2},
+			num_calls: 1,
+		},
+		{
+			desc:      "thunk is not called",
+			expr:      `(ignore (fail) 42)`,
+			expected:  []any{42},
+			num_calls: 0,
+		},
+		{
+			desc:      "value is given as thunk",
+			expr:      `(fallback (fail) "Rick" nil)`,
+			expected:  []any{"Rick"},
+			num_calls: 1,
+		},
+		{
+			desc:      "piped value is given as thunk",
+			expr:      `(pass 42 | fallback (fail))`,
+			expected:  []any{42},
+			num_calls: 1,
+		},
+		{
+			desc:      "nested pipeline after thunk is evaluated",
+			expr:      `(ignore (fail) (pass 42))`,
+			expected:  []any{42},
+			num_calls: 0,
+		},
+		{
+			desc:      "thunk runs with variables and data",
+			expr:      `(let $x 1 | fallback (fail) (pass $x $.y))`,
+			expected:  []any{1, 2},
+			num_calls: 1,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			num_calls = 0
+			rst, err := executor.ExecuteExpr(tc.expr, map[string]any{"y": 2})
+			require.NoError(err)
+			require.Equal(tc.expected, rst)
+			require.Equal(tc.num_calls, num_calls)
+		})
+	}
+
+	t.Run("number of arguments is checked if nested pipelines are thunks", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := executor.ExecuteExpr(`(ignore (pass 1 2))`, nil)
+		require.ErrorIs(err, pl.ErrArity)
+	})
+
+	t.Run("fails if position of nested pipeline that may be thunk is not fixed", func(t *testing.T) {
+		for _, expr := range []string{
+			`(retry (pass 3) (flaky 3))`,
+			`(ignore $.xs[*] (fail))`,
+			`(pass 1 | fallback _ (fail))`,
+		} {
+			t.Run(expr, func(t *testing.T) {
+				require := require.New(t)
+
+				num_calls = 0
+				_, err := executor.ExecuteExpr(expr, map[string]any{"xs": []any{1}})
+				require.ErrorContains(err, "position is not fixed")
+				require.Equal(0, num_calls)
+			})
+		}
+	})
+
+	t.Run("error from thunk is returned", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := executor.ExecuteExpr(`(retry 2 (fail))`, nil)
+		require.EqualError(err, "fn[0] retry: arg[1]: fn[0] fail: failed")
+	})
+}
+
+func TestExecutorExecuteContext(t *testing.T) {
+	type key struct{}
+
+	t.Run("function takes context as its first parameter", func(t *testing.T) {
+		require := require.New(t)
+
+		executor := pl.Executor{
+			Funcs: map[string]any{
+				"get": func(ctx context.Context, name string) string {
+					return fmt.Sprintf("%s: %v", name, ctx.Value(key{}))
+				},
+			},
+		}
+
+		ctx := context.WithValue(context.Background(), key{}, "Morty")
+		rst, err := executor.ExecuteExprContext(ctx, `(get "Rick" | get)`, nil)
+		require.NoError(err)
+		require.Equal([]any{"Rick: Morty: Morty"}, rst)
+	})
+
+	t.Run("stops if context is canceled", func(t *testing.T) {
+		require := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		invoked := []string{}
+		executor := pl.Executor{
+			Funcs: map[string]any{
+				"cancel": func(vs ...int) int {
+					invoked = append(invoked, "cancel")
+					cancel()
+					return 0
+				},
+				"sum": func(vs ...int) int {
+					invoked = append(invoked, "sum")
+					return 0
+				},
+			},
+		}
+
+		_, err := executor.ExecuteExprContext(ctx, `(sum | cancel | sum (sum))`, nil)
+		require.ErrorIs(err, context.Canceled)
+		require.ErrorContains(err, "fn[2] sum")
+		require.Equal([]string{"sum", "cancel"}, invoked)
+	})
+
+	t.Run("stops if deadline is exceeded", func(t *testing.T) {
+		require := require.New(t)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		executor := pl.Executor{
+			Funcs: map[string]any{
+				"sleep": func(ctx context.Context) int {
+					<-ctx.Done()
+					return 0
+				},
+				"sum": func(vs ...int) int { return 0 },
+			},
+		}
+
+		_, err := executor.ExecuteExprContext(ctx, `(sum 1 (sleep) 2)`, nil)
+		require.ErrorIs(err, context.DeadlineExceeded)
+	})
+}
+
+func TestExecutorTrace(t *testing.T) {
+	require := require.New(t)
+
+	traces := []string{}
+	executor := pl.NewExecutor()
+	executor.Trace = func(path []pl.Frame, args []any, rst any, err error) {
+		traces = append(traces, fmt.Sprintf("%d %s %v %v", len(path), path[len(path)-1].Name, args, rst))
+	}
+
+	_, err := executor.ExecuteExpr(`(pass 1 (printf "%d" 2) | pass 3)`, nil)
+	require.NoError(err)
+	require.Equal([]string{
+		"2 printf [%d 2] 2",
+		"1 pass [1 2] [1 2]",
+		"1 pass [3 1 2] [3 1 2]",
+	}, traces)
+}
@@ -1,6 +1,7 @@
 package pl
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -12,14 +13,21 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestExecutorEvaluateFn(t *testing.T) {
+func must[T any](obj T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+func TestProgramEvaluateArgs(t *testing.T) {
 	data := map[string]any{
 		"a": []map[string]string{{
 			"b": "foo",
 		}},
 	}
 
-	executor := Executor{}
+	executor := Executor{Funcs: FuncMap{"fn": func(vs ...any) []any { return vs }}}
 
 	t.Run("resolve arguments from args", func(t *testing.T) {
 		require := require.New(t)
@@ -27,15 +35,18 @@ func TestExecutorEvaluateFn(t *testing.T) {
 		ref, err := NewRef("a", 0, "b")
 		require.NoError(err)
 
-		args, err := NewArgs("string", 3.14, 42, ref, &Pl{})
+		args, err := NewArgs("string", 3.14, 42, ref, NewPl(&Fn{Name: "fn", Args: must(NewArgs(36))}))
 		require.NoError(err)
 
-		fn := &Fn{Name: "fn", Args: args}
-
-		node, err := executor.evaluateFn(fn, data)
+		prog, err := executor.Compile(NewPl(&Fn{Name: "fn", Args: args}))
 		require.NoError(err)
+
+		node := prog.root.fns[0]
 		require.Equal("fn", node.name)
-		require.ElementsMatch([]any{"string", 3.14, 42, "foo", &Pl{}}, node.args)
+
+		vs, _, err := prog.evaluateArgs(context.Background(), node, env{data: data}, nil)
+		require.NoError(err)
+		require.ElementsMatch([]any{"string", 3.14, 42, "foo", 36}, vs)
 	})
 
 	t.Run("fails if reference is not resolved", func(t *testing.T) {
@@ -47,9 +58,10 @@ func TestExecutorEvaluateFn(t *testing.T) {
 		args, err := NewArgs("string", ref)
 		require.NoError(err)
 
-		fn := &Fn{Name: "fn", Args: args}
+		prog, err := executor.Compile(NewPl(&Fn{Name: "fn", Args: args}))
+		require.NoError(err)
 
-		_, err = executor.evaluateFn(fn, data)
+		_, _, err = prog.evaluateArgs(context.Background(), prog.root.fns[0], env{data: data}, nil)
 		require.Error(err)
 		require.ErrorContains(err, "arg[1]")
 		require.ErrorContains(err, "reference")
@@ -63,9 +75,7 @@ func TestExecutorEvaluateFn(t *testing.T) {
 
 		args = append(args, &Arg{})
 
-		fn := &Fn{Name: "fn", Args: args}
-
-		_, err = executor.evaluateFn(fn, data)
+		_, err = executor.Compile(NewPl(&Fn{Name: "fn", Args: args}))
 		require.Error(err)
 		require.ErrorContains(err, "arg[2]")
 		require.ErrorContains(err, "empty")
@@ -73,14 +83,23 @@ func TestExecutorEvaluateFn(t *testing.T) {
 }
 
 func TestExecutorInvoke(t *testing.T) {
-	executor := Executor{Convs: defaultConversions}
+	executor := Executor{Convs: NewConvMap()}
 	executor.Convs.Set(string_t, reflect.TypeOf(float64(0)), func(v reflect.Value) (any, error) {
 		return strconv.ParseFloat(v.String(), 64)
 	})
-	executor.Convs.Set(reflect.TypeOf(float32(0)), string_t, func(v reflect.Value) (any, error) {
+	executor.Convs.Set(reflect.TypeOf(complex64(0)), string_t, func(v reflect.Value) (any, error) {
 		return nil, errors.New("fail")
 	})
 
+	invoke := func(fn any, args []any) (any, error) {
+		c, err := newCallable(fn)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.invoke(context.Background(), args, newConvCache(executor.Convs, executor.Strict).find)
+	}
+
 	sum := func(vs ...int) int {
 		rst := 0
 		for _, v := range vs {
@@ -189,6 +208,18 @@ func TestExecutorInvoke(t *testing.T) {
 			})()},
 			rst: "3.14",
 		},
+		{
+			desc: "invoke a function that takes context",
+			fn:   func(ctx context.Context, v int) int { return v * 2 },
+			args: []any{17},
+			rst:  34,
+		},
+		{
+			desc: "invoke a variadic function that takes context",
+			fn:   func(ctx context.Context, vs ...int) int { return len(vs) },
+			args: []any{1, 2, 3},
+			rst:  3,
+		},
 		{
 			desc: "function can returns an error",
 			fn:   func() (string, error) { return "Zoidberg", nil },
@@ -200,7 +231,7 @@ func TestExecutorInvoke(t *testing.T) {
 		t.Run(tc.desc, func(t *testing.T) {
 			require := require.New(t)
 
-			rst, err := executor.invokeFn(tc.fn, tc.args)
+			rst, err := invoke(tc.fn, tc.args)
 			require.NoError(err)
 			require.Equal(tc.rst, rst)
 		})
@@ -279,10 +310,10 @@ func TestExecutorInvoke(t *testing.T) {
 				msgs: []string{"not found"},
 			},
 			{
-				// float32->string fails.
+				// complex64->string fails.
 				desc: "invoke a function with intermediate conversion to fails",
 				fn:   func(v float64) string { return "" },
-				args: []any{float32(3.14)},
+				args: []any{complex64(3.14)},
 				msgs: []string{"to intermediate"},
 			},
 			{
@@ -301,7 +332,7 @@ func TestExecutorInvoke(t *testing.T) {
 			t.Run(tc.desc, func(t *testing.T) {
 				require := require.New(t)
 
-				_, err := executor.invokeFn(tc.fn, tc.args)
+				_, err := invoke(tc.fn, tc.args)
 				require.Error(err)
 				for _, msg := range tc.msgs {
 					require.ErrorContains(err, msg)
@@ -310,3 +341,33 @@ func TestExecutorInvoke(t *testing.T) {
 		}
 	})
 }
+
+func TestExecutorConvCache(t *testing.T) {
+	require := require.New(t)
+
+	type T struct{ V string }
+	t_t := reflect.TypeOf(T{})
+
+	executor := NewExecutor()
+	executor.Funcs["t"] = func(v T) string { return v.V }
+
+	data := map[string]any{"v": "x"}
+	prog := must(executor.Compile(must(ParseString(`(t $.v)`))))
+	_, err := prog.Run(data)
+	require.ErrorIs(err, ErrNotFound)
+	_, err = executor.ExecuteExpr(`(t "y")`, nil)
+	require.ErrorIs(err, ErrNotFound)
+
+	// Set directly without ConvMap.Set.
+	executor.Convs[string_t] = map[reflect.Type]func(v reflect.Value) (any, error){
+		t_t: func(v reflect.Value) (any, error) { return T{V: v.String()}, nil },
+	}
+
+	rst, err := prog.Run(data)
+	require.NoError(err)
+	require.Equal([]any{"x"}, rst)
+
+	rst, err = executor.ExecuteExpr(`(t "y")`, nil)
+	require.NoError(err)
+	require.Equal([]any{"y"}, rst)
+}
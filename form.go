@@ -0,0 +1,252 @@
+package pl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/lesomnus/pl/funcs"
+)
+
+// Special forms are evaluated by the executor instead of being invoked as a function
+// so their names take precedence over functions in the FuncMap.
+
+// scope is a variable bound by `let` or `as` that is linked to the variables bound before.
+type scope struct {
+	name   string
+	value  any
+	parent *scope
+}
+
+func (s *scope) with(name string, value any) *scope {
+	return &scope{name: name, value: value, parent: s}
+}
+
+func (s *scope) lookup(name string) (any, bool) {
+	for ; s != nil; s = s.parent {
+		if s.name == name {
+			return s.value, true
+		}
+	}
+
+	return nil, false
+}
+
+// compileBind compiles `let $x args...` that binds values of the arguments to $x
+// and `as $x` that binds results of the previous function to $x.
+// Both pass results of the previous function to the next function as they are.
+func (p *Program) compileBind(rst *fnNode, fn *Fn, vars *scope, is_piped bool) (*fnNode, error) {
+	if len(fn.Args) == 0 || fn.Args[0].Var == nil || len(fn.Args[0].Ref) > 0 {
+		return nil, rst.fail(-1, fmt.Errorf("%s requires a variable name such as $x", fn.Name))
+	}
+
+	rst.bind = *fn.Args[0].Var
+	switch fn.Name {
+	case "let":
+		if len(fn.Args) == 1 {
+			return nil, rst.fail(-1, errors.New("let requires a value to bind"))
+		}
+
+		// The variable is not visible to its value.
+		if err := p.compileArgs(rst, fn, vars, is_piped); err != nil {
+			return nil, err
+		}
+
+	case "as":
+		if !is_piped {
+			return nil, rst.fail(-1, errors.New("as is used in the first function of the pipeline"))
+		}
+		if len(fn.Args) > 1 {
+			return nil, rst.fail(1, errors.New("as takes only a variable name"))
+		}
+	}
+
+	return rst, nil
+}
+
+// runBind returns the value to be bound by `let` or `as`.
+// Multiple values are bound as a slice.
+func (p *Program) runBind(ctx context.Context, fn *fnNode, e env, args_prev []any) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fn.fail(-1, err)
+	}
+
+	vs := args_prev
+	if fn.name == "let" {
+		vs = []any{}
+		for i, arg := range fn.args[1:] {
+			v, err := p.evaluateSpread(ctx, fn, i+1, arg, e, args_prev)
+			if err != nil {
+				return nil, err
+			}
+
+			vs = append(vs, v...)
+		}
+	}
+
+	if len(vs) == 1 {
+		return vs[0], nil
+	}
+
+	return append([]any{}, vs...), nil
+}
+
+// IsTrue reports whether the value is true as a condition of `if`, `and`, and `or`.
+// As in text/template, false, zero numbers, nil, and empty strings, arrays, slices, and maps
+// are false and the other values including structs are true.
+func IsTrue(v any) bool {
+	return funcs.IsTrue(v)
+}
+
+func isNil(v any) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+
+	switch rv.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.UnsafePointer:
+		return rv.IsNil()
+	}
+
+	return false
+}
+
+// formArities are the minimum and maximum number of operands of the special forms
+// that evaluate their operands only if needed.
+// The maximum is -1 if it is not limited.
+var formArities = map[string][2]int{
+	"if":       {2, 3},
+	"and":      {1, -1},
+	"or":       {1, -1},
+	"coalesce": {1, -1},
+	"default":  {2, 2},
+}
+
+func checkFormArity(name string, n int, exact bool) error {
+	arity := formArities[name]
+	if arity[1] >= 0 && n > arity[1] {
+		return fmt.Errorf("%w: expected at most %d args but %d args are given", ErrArity, arity[1], n)
+	}
+	if exact && n < arity[0] {
+		return fmt.Errorf("%w: expected at least %d args but %d args are given", ErrArity, arity[0], n)
+	}
+
+	return nil
+}
+
+// compileForm compiles the special forms that evaluate their operands only if needed:
+//
+//	(if cond then else?) gives results of `then` if `cond` is true or results of `else` otherwise.
+//	(and x y...) gives the first operand that is false or the last one.
+//	(or x y...) gives the first operand that is true or the last one.
+//	(coalesce x y...) gives the first operand that is not nil.
+//	(default d x) gives `x` if it is true or `d` otherwise.
+//
+// Results of the previous function are operands following the explicit ones
+// unless they are placed by the placeholder.
+// `coalesce` and `default` regard a reference that is not found as nil.
+func (p *Program) compileForm(rst *fnNode, fn *Fn, vars *scope, is_piped bool) (*fnNode, error) {
+	rst.form = fn.Name
+	if err := p.compileArgs(rst, fn, vars, is_piped); err != nil {
+		return nil, err
+	}
+	if err := checkFormArity(rst.form, len(rst.args), !is_piped || rst.has_placeholder); err != nil {
+		return nil, rst.fail(-1, err)
+	}
+
+	return rst, nil
+}
+
+// runForm runs the special form compiled by compileForm.
+func (p *Program) runForm(ctx context.Context, fn *fnNode, e env, args_prev []any) ([]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fn.fail(-1, err)
+	}
+
+	operands := fn.args
+	if !fn.has_placeholder && len(args_prev) > 0 {
+		operands = make([]*argNode, len(fn.args), len(fn.args)+len(args_prev))
+		copy(operands, fn.args)
+		for _, v := range args_prev {
+			operands = append(operands, &argNode{value: v})
+		}
+	}
+	if err := checkFormArity(fn.form, len(operands), true); err != nil {
+		return nil, fn.fail(-1, err)
+	}
+
+	tolerant := fn.form == "coalesce" || fn.form == "default"
+	eval := func(i int) (any, error) {
+		v, err := p.evaluateArg(ctx, fn, i, operands[i], e, args_prev)
+		if err != nil && tolerant && operands[i].ref != nil && errors.Is(err, ErrRefNotFound) {
+			return nil, nil
+		}
+
+		return v, err
+	}
+
+	switch fn.form {
+	case "if":
+		cond, err := eval(0)
+		if err != nil {
+			return nil, err
+		}
+
+		i := 1
+		if !IsTrue(cond) {
+			if len(operands) < 3 {
+				return []any{}, nil
+			}
+			i = 2
+		}
+
+		return p.evaluateSpread(ctx, fn, i, operands[i], e, args_prev)
+
+	case "and", "or":
+		var v any
+		for i := range operands {
+			var err error
+			if v, err = eval(i); err != nil {
+				return nil, err
+			}
+			if IsTrue(v) == (fn.form == "or") {
+				break
+			}
+		}
+
+		return spread(v), nil
+
+	case "coalesce":
+		for i := range operands {
+			v, err := eval(i)
+			if err != nil {
+				return nil, err
+			}
+			if !isNil(v) {
+				return spread(v), nil
+			}
+		}
+
+		return []any{nil}, nil
+
+	case "default":
+		v, err := eval(1)
+		if err != nil {
+			return nil, err
+		}
+		if IsTrue(v) {
+			return spread(v), nil
+		}
+
+		d, err := eval(0)
+		if err != nil {
+			return nil, err
+		}
+
+		return spread(d), nil
+	}
+
+	return nil, fn.fail(-1, fmt.Errorf("unknown special form %q", fn.form))
+}
@@ -1,6 +1,8 @@
 package pl
 
 import (
+	"reflect"
+
 	"github.com/lesomnus/pl/funcs"
 )
 
@@ -11,5 +13,52 @@ func NewFuncMap() FuncMap {
 		"pass":   funcs.Pass,
 		"printf": funcs.Printf,
 		"regex":  funcs.Regex,
+		"eq":     funcs.Eq,
+		"ne":     funcs.Ne,
+
+		"map":      funcs.Map,
+		"filter":   funcs.Filter,
+		"reduce":   funcs.Reduce,
+		"sort_by":  funcs.SortBy,
+		"group_by": funcs.GroupBy,
+		"any":      funcs.Any,
+		"all":      funcs.All,
+		"find":     funcs.Find,
 	}
 }
+
+// Single wraps a value returned by a function so it is given to the next function
+// as a single argument even if it is a slice.
+func Single(v any) any {
+	return single{v: v}
+}
+
+// Many wraps values returned by a function so they are given to the next function
+// as separate arguments.
+func Many(vs ...any) any {
+	return many{vs: vs}
+}
+
+// NoSpread wraps a function so its result is always given to the next function
+// as a single argument, e.g. a function that returns []byte.
+func NoSpread(fn any) any {
+	return noSpread{fn: fn}
+}
+
+type single struct{ v any }
+
+type many struct{ vs []any }
+
+type noSpread struct{ fn any }
+
+// Thunk is a parameter type of a function that takes a nested pipeline unevaluated.
+// Calling it runs the pipeline against the current data and variables,
+// so the function can decide when and how many times to run it.
+// A value other than a nested pipeline is given as a Thunk that returns the value.
+type Thunk func() ([]any, error)
+
+var thunk_t = reflect.TypeOf(Thunk(nil))
+
+func isThunk(t reflect.Type) bool {
+	return t == thunk_t || t == reflect.TypeOf((func() ([]any, error))(nil))
+}
@@ -1,6 +1,9 @@
 package funcs
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+)
 
 func Pass(vs ...any) []any {
 	return vs
@@ -9,3 +12,18 @@ func Pass(vs ...any) []any {
 func Printf(format string, vs ...any) string {
 	return fmt.Sprintf(format, vs...)
 }
+
+// Eq reports whether the values are equal.
+// Numbers are equal if they have the same value even if their types differ.
+func Eq(lhs any, rhs any) bool {
+	if c, err := compare(lhs, rhs); err == nil {
+		return c == 0
+	}
+
+	return reflect.DeepEqual(lhs, rhs)
+}
+
+// Ne reports whether the values are not equal.
+func Ne(lhs any, rhs any) bool {
+	return !Eq(lhs, rhs)
+}
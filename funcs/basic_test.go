@@ -1,6 +1,7 @@
 package funcs_test
 
 import (
+	"math"
 	"testing"
 
 	"github.com/lesomnus/pl/funcs"
@@ -22,3 +23,20 @@ func TestPrintf(t *testing.T) {
 	output := funcs.Printf("%s %d %.2f", "a", 42, 3.14)
 	require.Equal(output, "a 42 3.14")
 }
+
+func TestEq(t *testing.T) {
+	require := require.New(t)
+
+	require.True(funcs.Eq(42, 42.0))
+	require.True(funcs.Eq(uint8(1), 1))
+	require.True(funcs.Eq("a", "a"))
+	require.True(funcs.Eq(nil, nil))
+	require.True(funcs.Eq([]int{1}, []int{1}))
+	require.False(funcs.Eq(1, "1"))
+	require.False(funcs.Eq(true, 1))
+	require.False(funcs.Eq(9007199254740993, 9007199254740992))
+	require.False(funcs.Eq(uint64(math.MaxUint64), -1))
+	require.True(funcs.Eq(uint64(math.MaxInt64), int64(math.MaxInt64)))
+	require.False(funcs.Ne(42, 42.0))
+	require.True(funcs.Ne("a", "b"))
+}
@@ -0,0 +1,259 @@
+package funcs
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Lambda is a function given by a lambda expression such as `{x -> printf "v%s" $x}`.
+// It returns results of the pipeline in its body.
+type Lambda = func(args ...any) ([]any, error)
+
+// IsTrue reports whether the value is true as a condition.
+// As in text/template, false, zero numbers, nil, and empty strings, arrays, slices, and maps
+// are false and the other values including structs are true.
+func IsTrue(v any) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return false
+	}
+
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() > 0
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Complex64, reflect.Complex128:
+		return rv.Complex() != 0
+	case reflect.Chan, reflect.Func, reflect.Pointer, reflect.Interface, reflect.UnsafePointer:
+		return !rv.IsNil()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() != 0
+	}
+
+	return true
+}
+
+// call calls the lambda and returns its result as a single value.
+// Multiple results are returned as a slice.
+func call(f Lambda, args ...any) (any, error) {
+	vs, err := f(args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(vs) == 1 {
+		return vs[0], nil
+	}
+
+	return vs, nil
+}
+
+// Map returns results of the lambda for each value.
+func Map(f Lambda, vs ...any) ([]any, error) {
+	rst := make([]any, len(vs))
+	for i, v := range vs {
+		u, err := call(f, v)
+		if err != nil {
+			return nil, err
+		}
+
+		rst[i] = u
+	}
+
+	return rst, nil
+}
+
+// Filter returns values for which the lambda gives a true value.
+func Filter(f Lambda, vs ...any) ([]any, error) {
+	rst := []any{}
+	for _, v := range vs {
+		ok, err := call(f, v)
+		if err != nil {
+			return nil, err
+		}
+		if IsTrue(ok) {
+			rst = append(rst, v)
+		}
+	}
+
+	return rst, nil
+}
+
+// Reduce accumulates values by the lambda that takes the accumulator and a value.
+func Reduce(f Lambda, init any, vs ...any) (any, error) {
+	acc := init
+	for _, v := range vs {
+		var err error
+		if acc, err = call(f, acc, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return acc, nil
+}
+
+// SortBy sorts values by keys given by the lambda.
+// Keys must be all numbers or all strings.
+func SortBy(f Lambda, vs ...any) ([]any, error) {
+	keys := make([]any, len(vs))
+	for i, v := range vs {
+		k, err := call(f, v)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[i] = k
+	}
+
+	indices := make([]int, len(vs))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	var err error
+	sort.SliceStable(indices, func(i, j int) bool {
+		c, e := compare(keys[indices[i]], keys[indices[j]])
+		if e != nil && err == nil {
+			err = e
+		}
+
+		return c < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rst := make([]any, len(vs))
+	for i, j := range indices {
+		rst[i] = vs[j]
+	}
+
+	return rst, nil
+}
+
+// GroupBy groups values by keys given by the lambda.
+// Keys are formatted as strings so groups can be referenced by name.
+func GroupBy(f Lambda, vs ...any) (map[string][]any, error) {
+	rst := map[string][]any{}
+	for _, v := range vs {
+		k, err := call(f, v)
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprint(k)
+		rst[key] = append(rst[key], v)
+	}
+
+	return rst, nil
+}
+
+// Any reports whether the lambda gives a true value for any of values.
+func Any(f Lambda, vs ...any) (bool, error) {
+	_, ok, err := find(f, vs)
+	return ok, err
+}
+
+// All reports whether the lambda gives a true value for all values.
+func All(f Lambda, vs ...any) (bool, error) {
+	for _, v := range vs {
+		ok, err := call(f, v)
+		if err != nil {
+			return false, err
+		}
+		if !IsTrue(ok) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Find returns the first value for which the lambda gives a true value, or nil if there is none.
+func Find(f Lambda, vs ...any) (any, error) {
+	v, _, err := find(f, vs)
+	return v, err
+}
+
+func find(f Lambda, vs []any) (any, bool, error) {
+	for _, v := range vs {
+		ok, err := call(f, v)
+		if err != nil {
+			return nil, false, err
+		}
+		if IsTrue(ok) {
+			return v, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// compare compares numbers or strings.
+// Integers are compared exactly and converted to float64 only if the other is a float.
+func compare(lhs any, rhs any) (int, error) {
+	l, r := reflect.ValueOf(lhs), reflect.ValueOf(rhs)
+	if l.Kind() == reflect.String && r.Kind() == reflect.String {
+		return compareOrdered(l.String(), r.String()), nil
+	}
+	if isInteger(l) && isInteger(r) {
+		return compareIntegers(l, r), nil
+	}
+
+	lf, ok_l := toFloat(l)
+	rf, ok_r := toFloat(r)
+	if !ok_l || !ok_r {
+		return 0, fmt.Errorf("cannot compare %T with %T", lhs, rhs)
+	}
+
+	return compareOrdered(lf, rf), nil
+}
+
+func compareOrdered[T int64 | uint64 | float64 | string](lhs T, rhs T) int {
+	switch {
+	case lhs < rhs:
+		return -1
+	case lhs > rhs:
+		return 1
+	}
+
+	return 0
+}
+
+func isInteger(v reflect.Value) bool {
+	return v.IsValid() && (v.CanInt() || v.CanUint())
+}
+
+func compareIntegers(l reflect.Value, r reflect.Value) int {
+	switch {
+	case l.CanInt() && r.CanInt():
+		return compareOrdered(l.Int(), r.Int())
+	case l.CanUint() && r.CanUint():
+		return compareOrdered(l.Uint(), r.Uint())
+	case l.CanInt():
+		if l.Int() < 0 {
+			return -1
+		}
+		return compareOrdered(uint64(l.Int()), r.Uint())
+	}
+
+	return -compareIntegers(r, l)
+}
+
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,145 @@
+package funcs_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/lesomnus/pl/funcs"
+	"github.com/stretchr/testify/require"
+)
+
+func lambda(f func(v any) any) funcs.Lambda {
+	return func(args ...any) ([]any, error) {
+		return []any{f(args[0])}, nil
+	}
+}
+
+func TestCollection(t *testing.T) {
+	double := lambda(func(v any) any { return v.(int) * 2 })
+	odd := lambda(func(v any) any { return v.(int)%2 == 1 })
+	fail := func(args ...any) ([]any, error) { return nil, errors.New("failed") }
+
+	t.Run("map", func(t *testing.T) {
+		require := require.New(t)
+
+		rst, err := funcs.Map(double, 1, 2, 3)
+		require.NoError(err)
+		require.Equal([]any{2, 4, 6}, rst)
+
+		pair := func(args ...any) ([]any, error) { return []any{args[0], args[0]}, nil }
+		rst, err = funcs.Map(pair, 1, 2)
+		require.NoError(err)
+		require.Equal([]any{[]any{1, 1}, []any{2, 2}}, rst)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		require := require.New(t)
+
+		rst, err := funcs.Filter(odd, 1, 2, 3)
+		require.NoError(err)
+		require.Equal([]any{1, 3}, rst)
+
+		rst, err = funcs.Filter(odd)
+		require.NoError(err)
+		require.Equal([]any{}, rst)
+	})
+
+	t.Run("reduce", func(t *testing.T) {
+		require := require.New(t)
+
+		sum := func(args ...any) ([]any, error) { return []any{args[0].(int) + args[1].(int)}, nil }
+		rst, err := funcs.Reduce(sum, 10, 1, 2, 3)
+		require.NoError(err)
+		require.Equal(16, rst)
+	})
+
+	t.Run("sort_by", func(t *testing.T) {
+		require := require.New(t)
+
+		identity := lambda(func(v any) any { return v })
+		rst, err := funcs.SortBy(identity, 3, 1.5, uint(2))
+		require.NoError(err)
+		require.Equal([]any{1.5, uint(2), 3}, rst)
+
+		rst, err = funcs.SortBy(identity, "b", "c", "a")
+		require.NoError(err)
+		require.Equal([]any{"a", "b", "c"}, rst)
+
+		rst, err = funcs.SortBy(identity, 9007199254740993, 9007199254740992, uint64(math.MaxUint64), -1)
+		require.NoError(err)
+		require.Equal([]any{-1, 9007199254740992, 9007199254740993, uint64(math.MaxUint64)}, rst)
+
+		parity := lambda(func(v any) any { return v.(int) % 2 })
+		rst, err = funcs.SortBy(parity, 1, 2, 3, 4)
+		require.NoError(err)
+		require.Equal([]any{2, 4, 1, 3}, rst)
+
+		_, err = funcs.SortBy(odd, 1, 2)
+		require.ErrorContains(err, "cannot compare")
+	})
+
+	t.Run("group_by", func(t *testing.T) {
+		require := require.New(t)
+
+		rst, err := funcs.GroupBy(odd, 1, 2, 3)
+		require.NoError(err)
+		require.Equal(map[string][]any{"true": {1, 3}, "false": {2}}, rst)
+	})
+
+	t.Run("any all find", func(t *testing.T) {
+		require := require.New(t)
+
+		ok, err := funcs.Any(odd, 2, 3)
+		require.NoError(err)
+		require.True(ok)
+
+		ok, err = funcs.All(odd, 1, 2)
+		require.NoError(err)
+		require.False(ok)
+
+		ok, err = funcs.All(odd)
+		require.NoError(err)
+		require.True(ok)
+
+		v, err := funcs.Find(odd, 2, 3, 5)
+		require.NoError(err)
+		require.Equal(3, v)
+
+		v, err = funcs.Find(odd, 2, 4)
+		require.NoError(err)
+		require.Nil(v)
+	})
+
+	t.Run("fails if lambda fails", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := funcs.Map(fail, 1)
+		require.ErrorContains(err, "failed")
+		_, err = funcs.Filter(fail, 1)
+		require.ErrorContains(err, "failed")
+		_, err = funcs.Reduce(fail, 0, 1)
+		require.ErrorContains(err, "failed")
+		_, err = funcs.SortBy(fail, 1)
+		require.ErrorContains(err, "failed")
+		_, err = funcs.GroupBy(fail, 1)
+		require.ErrorContains(err, "failed")
+		_, err = funcs.Any(fail, 1)
+		require.ErrorContains(err, "failed")
+		_, err = funcs.All(fail, 1)
+		require.ErrorContains(err, "failed")
+		_, err = funcs.Find(fail, 1)
+		require.ErrorContains(err, "failed")
+	})
+}
+
+func TestIsTrue(t *testing.T) {
+	require := require.New(t)
+
+	for _, v := range []any{true, 1, uint(1), -0.5, "a", []int{0}, map[string]int{"a": 0}, struct{}{}, &struct{}{}, complex(0, 1)} {
+		require.True(funcs.IsTrue(v), "%#v", v)
+	}
+	for _, v := range []any{nil, false, 0, uint(0), 0.0, "", []int{}, [0]int{}, map[string]int{}, (*int)(nil), complex(0, 0)} {
+		require.False(funcs.IsTrue(v), "%#v", v)
+	}
+}
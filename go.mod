@@ -5,10 +5,12 @@ go 1.19
 require (
 	github.com/alecthomas/participle/v2 v2.0.0-beta.5
 	github.com/stretchr/testify v1.8.1
+	golang.org/x/term v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
@@ -2,8 +2,12 @@ package pl
 
 import (
 	"fmt"
+	"sort"
 )
 
+// Placeholder is given to NewArgs to create a placeholder argument.
+type Placeholder struct{}
+
 func NewPl(fs ...*Fn) *Pl {
 	return &Pl{Funcs: fs}
 }
@@ -34,10 +38,48 @@ func NewArgs(args ...interface{}) ([]*Arg, error) {
 			rst[i].Float = &v
 		case int:
 			rst[i].Int = &v
+		case bool:
+			b := Boolean(v)
+			rst[i].Bool = &b
+		case nil:
+			rst[i].Null = true
+		case Placeholder:
+			rst[i].Placeholder = true
 		case Ref:
 			rst[i].Ref = v
 		case *Pl:
 			rst[i].Nested = v
+		case *Lambda:
+			rst[i].Lambda = v
+		case []any:
+			items, err := NewArgs(v...)
+			if err != nil {
+				return nil, fmt.Errorf("invalid item of list at %d: %w", i, err)
+			}
+
+			// Items are nil for an empty list as the parser does.
+			rst[i].List = &List{}
+			if len(items) > 0 {
+				rst[i].List.Items = items
+			}
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			var entries []*MapEntry
+			for _, key := range keys {
+				value, err := NewArgs(v[key])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value of map at %d: %w", i, err)
+				}
+
+				entries = append(entries, &MapEntry{Key: key, Value: value[0]})
+			}
+
+			rst[i].Map = &Map{Entries: entries}
 
 		default:
 			return nil, fmt.Errorf("invalid type of argument at %d", i)
@@ -56,6 +98,8 @@ func NewRef(entries ...interface{}) (Ref, error) {
 			rst[i].Name = &v
 		case int:
 			rst[i].Index = &v
+		case Slice:
+			rst[i].Slice = &v
 
 		default:
 			return nil, fmt.Errorf("invalid type of argument at %d", i)
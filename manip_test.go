@@ -73,6 +73,26 @@ func TestNewArgs(t *testing.T) {
 				{String: addr("36")},
 			},
 		},
+		{
+			desc:  "literals",
+			input: []interface{}{true, false, nil},
+			expected: []*pl.Arg{
+				{Bool: addr(pl.Boolean(true))},
+				{Bool: addr(pl.Boolean(false))},
+				{Null: true},
+			},
+		},
+		{
+			desc:  "collections",
+			input: []interface{}{[]any{1, []any{}}, map[string]any{"b": "c", "a": nil}},
+			expected: []*pl.Arg{
+				{List: &pl.List{Items: []*pl.Arg{{Int: addr(1)}, {List: &pl.List{}}}}},
+				{Map: &pl.Map{Entries: []*pl.MapEntry{
+					{Key: "a", Value: &pl.Arg{Null: true}},
+					{Key: "b", Value: &pl.Arg{String: addr("c")}},
+				}}},
+			},
+		},
 		{
 			desc:     "reference",
 			input:    []interface{}{pl.Ref{{Name: addr("b")}, {Index: addr(1)}, {Name: addr("c")}}},
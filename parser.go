@@ -2,37 +2,185 @@ package pl
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
 )
 
 type Pl struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
 	Funcs []*Fn `parser:"'(' ( @@ ( '|' @@ )* )? ')'"`
 }
 
 type Fn struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
 	Name string `parser:"@Ident"`
 	Args []*Arg `parser:"@@*"`
 }
 
 type Arg struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
 	String *string  `parser:"  @String"`
-	Float  *float64 `parser:"| @Float"`
-	Int    *int     `parser:"| @Int"`
-	Ref    Ref      `parser:"| '$' @@+"`
+	Float  *float64 `parser:"| @(('-' | '+')? Float)"`
+	Int    *int     `parser:"| @(('-' | '+')? Int)"`
+	Bool   *Boolean `parser:"| @('true' | 'false')"`
+	Null   bool     `parser:"| @'nil'"`
 	Nested *Pl      `parser:"| @@"`
+	List   *List    `parser:"| @@"`
+	Lambda *Lambda  `parser:"| @@"`
+	Map    *Map     `parser:"| @@"`
+
+	// A reference without `$` such as `.kind` is parsed only to be rejected by ParseString with a clear error.
+	Var *string `parser:"| '$' ( @Ident"`
+	Ref Ref     `parser:"      @@* | @@+ ) | @@+"`
+
+	// Root is a bare `$` that refers to the whole data, or the element in a filter.
+	Root bool `parser:"| @'$'"`
+
+	// Placeholder marks where results of the previous function are spliced.
+	Placeholder bool `parser:"| @'_'"`
+}
+
+type List struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Items []*Arg `parser:"'[' ( @@ ( ',' @@ )* ','? )? ']'"`
+}
+
+// Lambda is a pipeline that is run with its parameters bound as variables.
+type Lambda struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Params []string `parser:"'{' @Ident ( ',' @Ident )* '-' '>'"`
+	Funcs  []*Fn    `parser:"@@ ( '|' @@ )* '}'"`
+}
+
+type Map struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Entries []*MapEntry `parser:"'{' ( @@ ( ',' @@ )* ','? )? '}'"`
+}
+
+type MapEntry struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Key   string `parser:"@(Ident | String) ':'"`
+	Value *Arg   `parser:"@@"`
+}
+
+// Boolean is a bool captured from the literal `true` or `false`.
+type Boolean bool
+
+func (b *Boolean) Capture(values []string) error {
+	*b = values[0] == "true"
+	return nil
 }
 
 type RefKey struct {
-	Name  *string `parser:"  (('.' @(Ident|String)) | ('[' @(Ident|String) ']'))"`
-	Index *int    `parser:"| '[' @Int ']'"`
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Name  *string `parser:"( (('.' @(Ident|String)) | ('[' @(Ident|String) ']'))"`
+	Index *int    `parser:"| '[' @('-'? Int) ']'"`
+	Slice *Slice  `parser:"| @@"`
+
+	// Filter selects elements of a list or values of a map that satisfy the predicate.
+	Filter *Filter `parser:"| @@"`
+
+	// Wildcard refers to all elements of a list, values of a map, or fields of a struct.
+	Wildcard bool `parser:"| @( '.' '*' | '[' '*' ']' )"`
+
+	// Descent refers to values of the key in the value and all of its descendants.
+	Descent *string `parser:"| '.' '.' @(Ident|String) )"`
+
+	// Optional denotes that the reference is nil if the key is missing or its value is nil.
+	Optional bool `parser:"@'?'?"`
+}
+
+// Slice is a range of a list or a string such as `[1:3]`, `[:2]`, or `[::-1]`.
+// As in Python, negative bounds count from the end and the bounds are clamped to the length.
+type Slice struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Start *int `parser:"'[' @('-'? Int)? ':'"`
+	End   *int `parser:"@('-'? Int)?"`
+	Step  *int `parser:"( ':' @('-'? Int)? )? ']'"`
+}
+
+func (s *Slice) String() string {
+	b := strings.Builder{}
+	b.WriteString("[")
+	if s.Start != nil {
+		b.WriteString(strconv.Itoa(*s.Start))
+	}
+	b.WriteString(":")
+	if s.End != nil {
+		b.WriteString(strconv.Itoa(*s.End))
+	}
+	if s.Step != nil {
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(*s.Step))
+	}
+	b.WriteString("]")
+
+	return b.String()
+}
+
+// Filter is a predicate in a reference such as `[?(.enabled)]` or `[?(eq $.kind "Deployment")]`.
+// The predicate is a reference or a pipeline whose data is the element being tested.
+type Filter struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Ref Ref `parser:"'[' '?' ( '(' @@+ ')'"`
+	Pl  *Pl `parser:"| @@ ) ']'"`
+}
+
+func (f *Filter) String() string {
+	if f.Pl != nil {
+		return fmt.Sprintf("[?%s]", f.Pl.String())
+	}
+
+	return fmt.Sprintf("[?(%s)]", f.Ref.String())
 }
 
 func (k *RefKey) String() string {
+	optional := ""
+	if k.Optional {
+		optional = "?"
+	}
+
 	if k.Name != nil {
-		return fmt.Sprintf(".%s", *k.Name)
+		if !isIdent(*k.Name) {
+			return fmt.Sprintf("[%s]%s", strconv.Quote(*k.Name), optional)
+		}
+		return fmt.Sprintf(".%s%s", *k.Name, optional)
 	} else if k.Index != nil {
-		return fmt.Sprintf("[%d]", *k.Index)
+		return fmt.Sprintf("[%d]%s", *k.Index, optional)
+	} else if k.Slice != nil {
+		return k.Slice.String() + optional
+	} else if k.Filter != nil {
+		return k.Filter.String() + optional
+	} else if k.Wildcard {
+		return "[*]" + optional
+	} else if k.Descent != nil {
+		if !isIdent(*k.Descent) {
+			return fmt.Sprintf("..%s%s", strconv.Quote(*k.Descent), optional)
+		}
+		return fmt.Sprintf("..%s%s", *k.Descent, optional)
 	} else {
 		return ".?"
 	}
@@ -40,8 +188,88 @@ func (k *RefKey) String() string {
 
 var plParser = participle.MustBuild[Pl](
 	participle.Unquote("String"),
+	// A list literal that follows a reference, such as `$.a [-1, 2]`,
+	// can be parsed only after an index or a slice of the reference is tried,
+	// and a map literal only after a lambda is tried.
+	participle.UseLookahead(3),
 )
 
 func ParseString(expr string) (*Pl, error) {
-	return plParser.ParseString("", expr)
+	p, err := plParser.ParseString("", expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRefsInPl(expr, p.Funcs); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// checkRefsInPl fails if a key of a reference is separated by spaces from the reference,
+// so `(eq $kind .kind)` is not read as `(eq $kind.kind)` and `(f $.a [1])` is not read as `(f $.a[1])`.
+func checkRefsInPl(expr string, fns []*Fn) error {
+	for _, fn := range fns {
+		for _, arg := range fn.Args {
+			if err := checkRefsInArg(expr, arg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkRefsInArg(expr string, arg *Arg) error {
+	switch {
+	case arg.Ref != nil:
+		if o := arg.Pos.Offset; arg.Var == nil && o < len(expr) && expr[o] != '$' {
+			return participle.Errorf(arg.Pos, "unexpected %q; a reference starts with \"$\"", arg.Ref.String())
+		}
+
+		return checkRef(expr, arg.Ref)
+	case arg.Nested != nil:
+		return checkRefsInPl(expr, arg.Nested.Funcs)
+	case arg.Lambda != nil:
+		return checkRefsInPl(expr, arg.Lambda.Funcs)
+	case arg.List != nil:
+		for _, item := range arg.List.Items {
+			if err := checkRefsInArg(expr, item); err != nil {
+				return err
+			}
+		}
+	case arg.Map != nil:
+		for _, entry := range arg.Map.Entries {
+			if err := checkRefsInArg(expr, entry.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkRef(expr string, ref Ref) error {
+	for _, k := range ref {
+		if o := k.Pos.Offset; o > 0 && o < len(expr) && strings.ContainsRune(" \t\r\n", rune(expr[o-1])) {
+			switch expr[o] {
+			case '.':
+				return participle.Errorf(k.Pos, "unexpected %q after spaces in reference; a reference starts with \"$\"", k.String())
+			case '[':
+				return participle.Errorf(k.Pos, "unexpected %q after spaces in reference; an index follows the reference without spaces and a list of one item needs a trailing comma", k.String())
+			}
+		}
+		if f := k.Filter; f != nil {
+			if err := checkRef(expr, f.Ref); err != nil {
+				return err
+			}
+			if f.Pl != nil {
+				if err := checkRefsInPl(expr, f.Pl.Funcs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
 }
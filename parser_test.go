@@ -3,10 +3,76 @@ package pl_test
 import (
 	"testing"
 
+	"github.com/alecthomas/participle/v2/lexer"
 	"github.com/lesomnus/pl"
 	"github.com/stretchr/testify/require"
 )
 
+// withoutPos clears positions recorded by the parser
+// so the parsed pipeline can be compared with the one built manually.
+func withoutPos(p *pl.Pl) *pl.Pl {
+	p.Pos = lexer.Position{}
+	p.EndPos = lexer.Position{}
+	for _, fn := range p.Funcs {
+		fn.Pos = lexer.Position{}
+		fn.EndPos = lexer.Position{}
+		for _, arg := range fn.Args {
+			argWithoutPos(arg)
+		}
+	}
+
+	return p
+}
+
+func argWithoutPos(arg *pl.Arg) {
+	arg.Pos = lexer.Position{}
+	arg.EndPos = lexer.Position{}
+	refWithoutPos(arg.Ref)
+	if arg.Nested != nil {
+		withoutPos(arg.Nested)
+	}
+	if arg.List != nil {
+		arg.List.Pos = lexer.Position{}
+		arg.List.EndPos = lexer.Position{}
+		for _, item := range arg.List.Items {
+			argWithoutPos(item)
+		}
+	}
+	if arg.Lambda != nil {
+		arg.Lambda.Pos = lexer.Position{}
+		arg.Lambda.EndPos = lexer.Position{}
+		withoutPos(&pl.Pl{Funcs: arg.Lambda.Funcs})
+	}
+	if arg.Map != nil {
+		arg.Map.Pos = lexer.Position{}
+		arg.Map.EndPos = lexer.Position{}
+		for _, entry := range arg.Map.Entries {
+			entry.Pos = lexer.Position{}
+			entry.EndPos = lexer.Position{}
+			argWithoutPos(entry.Value)
+		}
+	}
+}
+
+func refWithoutPos(ref pl.Ref) {
+	for i := range ref {
+		ref[i].Pos = lexer.Position{}
+		ref[i].EndPos = lexer.Position{}
+		if s := ref[i].Slice; s != nil {
+			s.Pos = lexer.Position{}
+			s.EndPos = lexer.Position{}
+		}
+		if f := ref[i].Filter; f != nil {
+			f.Pos = lexer.Position{}
+			f.EndPos = lexer.Position{}
+			refWithoutPos(f.Ref)
+			if f.Pl != nil {
+				withoutPos(f.Pl)
+			}
+		}
+	}
+}
+
 func TestParse(t *testing.T) {
 	tcs := []struct {
 		desc     string
@@ -38,6 +104,142 @@ func TestParse(t *testing.T) {
 				must(pl.NewFn("a", "b", 42, must(pl.NewRef("a", 1, "b")), 3.14, "36")),
 			),
 		},
+		{
+			desc:  "function with boolean and null arguments",
+			input: `(a true false nil "nil")`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a", true, false, nil, "nil")),
+			),
+		},
+		{
+			desc:  "function with list and map arguments",
+			input: `(a [1, "b", $.c, (d)] {e: 1, "f-g": [true,]} [] {})`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a",
+					[]any{1, "b", must(pl.NewRef("c")), pl.NewPl(must(pl.NewFn("d")))},
+					map[string]any{"e": 1, "f-g": []any{true}},
+					[]any{},
+					map[string]any{},
+				)),
+			),
+		},
+		{
+			desc:  "function with root reference arguments",
+			input: `(a $ [$])`,
+			expected: pl.NewPl(&pl.Fn{Name: "a", Args: []*pl.Arg{
+				{Root: true},
+				{List: &pl.List{Items: []*pl.Arg{{Root: true}}}},
+			}}),
+		},
+		{
+			desc:  "function with variable arguments",
+			input: `(a $x $x.b[0] $y["c-d"] $.z)`,
+			expected: pl.NewPl(&pl.Fn{Name: "a", Args: []*pl.Arg{
+				{Var: addr("x")},
+				{Var: addr("x"), Ref: must(pl.NewRef("b", 0))},
+				{Var: addr("y"), Ref: must(pl.NewRef("c-d"))},
+				{Ref: must(pl.NewRef("z"))},
+			}}),
+		},
+		{
+			desc:  "function with lambda arguments",
+			input: `(a {x -> b $x} {x, y -> c | d $y} {x: 1})`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a",
+					&pl.Lambda{Params: []string{"x"}, Funcs: []*pl.Fn{
+						{Name: "b", Args: []*pl.Arg{{Var: addr("x")}}},
+					}},
+					&pl.Lambda{Params: []string{"x", "y"}, Funcs: []*pl.Fn{
+						must(pl.NewFn("c")),
+						{Name: "d", Args: []*pl.Arg{{Var: addr("y")}}},
+					}},
+					map[string]any{"x": 1},
+				)),
+			),
+		},
+		{
+			desc:  "function with optional reference arguments",
+			input: `(a $.b?.c?[0]? $x["d-e"]?.f)`,
+			expected: pl.NewPl(&pl.Fn{Name: "a", Args: []*pl.Arg{
+				{Ref: pl.Ref{
+					{Name: addr("b"), Optional: true},
+					{Name: addr("c"), Optional: true},
+					{Index: addr(0), Optional: true},
+				}},
+				{Var: addr("x"), Ref: pl.Ref{
+					{Name: addr("d-e"), Optional: true},
+					{Name: addr("f")},
+				}},
+			}}),
+		},
+		{
+			desc:  "function with index and slice reference arguments",
+			input: `(a $.b[-1] $.c[1:3] $[:-2] $.d[::2] $.e[:]?)`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a",
+					must(pl.NewRef("b", -1)),
+					must(pl.NewRef("c", pl.Slice{Start: addr(1), End: addr(3)})),
+					must(pl.NewRef(pl.Slice{End: addr(-2)})),
+					must(pl.NewRef("d", pl.Slice{Step: addr(2)})),
+					pl.Ref{{Name: addr("e")}, {Slice: &pl.Slice{}, Optional: true}},
+				)),
+			),
+		},
+		{
+			desc:  "function with wildcard and recursive descent reference arguments",
+			input: `(a $.b[*].c $.*[0] $..d $.e.."f-g"?)`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a",
+					pl.Ref{{Name: addr("b")}, {Wildcard: true}, {Name: addr("c")}},
+					pl.Ref{{Wildcard: true}, {Index: addr(0)}},
+					pl.Ref{{Descent: addr("d")}},
+					pl.Ref{{Name: addr("e")}, {Descent: addr("f-g"), Optional: true}},
+				)),
+			),
+		},
+		{
+			desc:  "function with filter reference arguments",
+			input: `(a $.g $.b[?(.c)].d $[?(eq $.e "f" | ne false)]?)`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a",
+					must(pl.NewRef("g")),
+					pl.Ref{{Name: addr("b")}, {Filter: &pl.Filter{Ref: must(pl.NewRef("c"))}}, {Name: addr("d")}},
+					pl.Ref{{Filter: &pl.Filter{Pl: pl.NewPl(
+						must(pl.NewFn("eq", must(pl.NewRef("e")), "f")),
+						must(pl.NewFn("ne", false)),
+					)}, Optional: true}},
+				)),
+			),
+		},
+		{
+			desc:  "list literal after reference",
+			input: `(a $.b [1, 2])`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a", must(pl.NewRef("b")), []any{1, 2})),
+			),
+		},
+		{
+			desc:  "list literal of negative numbers after reference",
+			input: `(a $.b [-1, 2])`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a", must(pl.NewRef("b")), []any{-1, 2})),
+			),
+		},
+		{
+			desc:  "list literal of one item after reference",
+			input: `(a $.b [1,])`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a", must(pl.NewRef("b")), []any{1})),
+			),
+		},
+		{
+			desc:  "placeholder",
+			input: `(a 1 | b _ [_] "_")`,
+			expected: pl.NewPl(
+				must(pl.NewFn("a", 1)),
+				must(pl.NewFn("b", pl.Placeholder{}, []any{pl.Placeholder{}}, "_")),
+			),
+		},
 		{
 			desc:  "sequence of functions",
 			input: `(a "b" 42 3.14 "36" | c "d" 21)`,
@@ -88,7 +290,94 @@ func TestParse(t *testing.T) {
 
 			fns, err := pl.ParseString(tc.input)
 			require.NoError(err)
-			require.Equal(tc.expected, fns)
+			require.Equal(tc.expected, withoutPos(fns))
+		})
+	}
+}
+
+func TestParseFails(t *testing.T) {
+	tcs := []struct {
+		desc  string
+		input string
+		msgs  []string
+	}{
+		{
+			desc:  "key follows variable with spaces",
+			input: `(eq $kind .kind)`,
+			msgs:  []string{"1:11:", `unexpected ".kind" after spaces in reference`},
+		},
+		{
+			desc:  "key follows reference with spaces",
+			input: `(a $.b .c)`,
+			msgs:  []string{"1:8:", `".c"`},
+		},
+		{
+			desc:  "key follows reference with spaces in filter",
+			input: `(a [$.b[?(eq $kind .kind)]])`,
+			msgs:  []string{"1:20:", `".kind"`},
+		},
+		{
+			desc:  "reference does not start with $",
+			input: `(a .b)`,
+			msgs:  []string{"1:4:", `unexpected ".b"; a reference starts with "$"`},
+		},
+		{
+			desc:  "reference in predicate does not start with $",
+			input: `(a $.b[?(eq .kind "Deployment")])`,
+			msgs:  []string{"1:13:", `unexpected ".kind"; a reference starts with "$"`},
+		},
+		{
+			desc:  "index follows reference with spaces",
+			input: `(a $.b [1])`,
+			msgs:  []string{"1:8:", `unexpected "[1]" after spaces in reference`},
+		},
+		{
+			desc:  "index follows variable with spaces",
+			input: `(a $x [-1])`,
+			msgs:  []string{"1:7:", `"[-1]"`},
+		},
+		{
+			desc:  "wildcard follows reference with spaces",
+			input: `(a $.b .*)`,
+			msgs:  []string{"1:8:", `"[*]"`},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			_, err := pl.ParseString(tc.input)
+			for _, msg := range tc.msgs {
+				require.ErrorContains(err, msg)
+			}
 		})
 	}
 }
+
+func TestParsePosition(t *testing.T) {
+	require := require.New(t)
+
+	p, err := pl.ParseString(`(a "b" | c $.d[1] (e))`)
+	require.NoError(err)
+
+	offsets := func(pos lexer.Position, end_pos lexer.Position) []int {
+		return []int{pos.Offset, end_pos.Offset}
+	}
+
+	require.Equal([]int{0, 22}, offsets(p.Pos, p.EndPos))
+	require.Equal([]int{1, 7}, offsets(p.Funcs[0].Pos, p.Funcs[0].EndPos))
+	require.Equal([]int{3, 7}, offsets(p.Funcs[0].Args[0].Pos, p.Funcs[0].Args[0].EndPos))
+	require.Equal([]int{9, 21}, offsets(p.Funcs[1].Pos, p.Funcs[1].EndPos))
+	require.Equal([]int{11, 18}, offsets(p.Funcs[1].Args[0].Pos, p.Funcs[1].Args[0].EndPos))
+	require.Equal([]int{12, 14}, offsets(p.Funcs[1].Args[0].Ref[0].Pos, p.Funcs[1].Args[0].Ref[0].EndPos))
+	require.Equal([]int{14, 18}, offsets(p.Funcs[1].Args[0].Ref[1].Pos, p.Funcs[1].Args[0].Ref[1].EndPos))
+	require.Equal([]int{18, 21}, offsets(p.Funcs[1].Args[1].Pos, p.Funcs[1].Args[1].EndPos))
+
+	p, err = pl.ParseString(`(a $.b[1:2])`)
+	require.NoError(err)
+	require.Equal([]int{6, 11}, offsets(p.Funcs[0].Args[0].Ref[1].Slice.Pos, p.Funcs[0].Args[0].Ref[1].Slice.EndPos))
+
+	p, err = pl.ParseString(`(a $.b[?(.c)])`)
+	require.NoError(err)
+	require.Equal([]int{6, 13}, offsets(p.Funcs[0].Args[0].Ref[1].Filter.Pos, p.Funcs[0].Args[0].Ref[1].Filter.EndPos))
+}
@@ -0,0 +1,197 @@
+package pl
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// String returns the expression of the pipeline in canonical form.
+// The result is parsed into the same pipeline.
+func (p *Pl) String() string {
+	b := strings.Builder{}
+	b.WriteString("(")
+	for i, fn := range p.Funcs {
+		if i > 0 {
+			b.WriteString(" | ")
+		}
+		b.WriteString(fn.String())
+	}
+	b.WriteString(")")
+
+	return b.String()
+}
+
+// Pretty returns the expression of the pipeline in canonical form
+// but a pipeline that does not fit in `width` columns is broken into lines
+// with each function indented by a tab.
+func (p *Pl) Pretty(width int) string {
+	b := strings.Builder{}
+	p.pretty(&b, 0, 0, width)
+
+	return b.String()
+}
+
+// pretty writes the pipeline that starts at the column `col`
+// where the function is indented by `depth` tabs.
+func (p *Pl) pretty(b *strings.Builder, depth int, col int, width int) {
+	if s := p.String(); col+len(s) <= width || len(p.Funcs) == 0 {
+		b.WriteString(s)
+		return
+	}
+
+	b.WriteString("(")
+	for i, fn := range p.Funcs {
+		if i > 0 {
+			b.WriteString("\n")
+			b.WriteString(strings.Repeat("\t", depth+1))
+			b.WriteString("| ")
+			col = (depth+1)*tabWidth + 2
+		} else {
+			col++
+		}
+
+		b.WriteString(fn.Name)
+		col += len(fn.Name)
+		for _, arg := range fn.Args {
+			b.WriteString(" ")
+			col++
+			if arg.Nested != nil {
+				arg.Nested.pretty(b, depth+1, col, width)
+				col = lastLineWidth(b.String())
+				continue
+			}
+
+			s := arg.format()
+			b.WriteString(s)
+			col += len(s)
+		}
+	}
+	b.WriteString(")")
+}
+
+// tabWidth is the width of a tab assumed when measuring the column.
+const tabWidth = 4
+
+func lastLineWidth(s string) int {
+	line := s[strings.LastIndexByte(s, '\n')+1:]
+	return len(line) + strings.Count(line, "\t")*(tabWidth-1)
+}
+
+func (f *Fn) String() string {
+	b := strings.Builder{}
+	b.WriteString(f.Name)
+	for i, arg := range f.Args {
+		b.WriteString(" ")
+
+		s := arg.format()
+		if i > 0 && arg.List != nil && len(arg.List.Items) == 1 {
+			// A list of one item after a reference needs a trailing comma not to be an index.
+			if prev := f.Args[i-1]; prev.Var != nil || prev.Ref != nil || prev.Root {
+				s = strings.TrimSuffix(s, "]") + ",]"
+			}
+		}
+		b.WriteString(s)
+	}
+
+	return b.String()
+}
+
+// Format implements fmt.Formatter so the argument is printed in canonical form.
+// Arg cannot have String method since it has a field named String.
+func (a *Arg) Format(f fmt.State, verb rune) {
+	io.WriteString(f, a.format())
+}
+
+func (a *Arg) format() string {
+	if a.String != nil {
+		return strconv.Quote(*a.String)
+	} else if a.Float != nil {
+		return formatFloat(*a.Float)
+	} else if a.Int != nil {
+		return strconv.Itoa(*a.Int)
+	} else if a.Bool != nil {
+		return strconv.FormatBool(bool(*a.Bool))
+	} else if a.Null {
+		return "nil"
+	} else if a.Placeholder {
+		return "_"
+	} else if a.Var != nil {
+		return "$" + *a.Var + a.Ref.String()
+	} else if a.Ref != nil {
+		return "$" + a.Ref.String()
+	} else if a.Root {
+		return "$"
+	} else if a.Nested != nil {
+		return a.Nested.String()
+	} else if a.List != nil {
+		return a.List.String()
+	} else if a.Lambda != nil {
+		return a.Lambda.String()
+	} else if a.Map != nil {
+		return a.Map.String()
+	} else {
+		return "?"
+	}
+}
+
+func (l *List) String() string {
+	items := make([]string, len(l.Items))
+	for i, item := range l.Items {
+		items[i] = item.format()
+	}
+
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (l *Lambda) String() string {
+	body := (&Pl{Funcs: l.Funcs}).String()
+	return "{" + strings.Join(l.Params, ", ") + " -> " + body[1:len(body)-1] + "}"
+}
+
+func (m *Map) String() string {
+	entries := make([]string, len(m.Entries))
+	for i, entry := range m.Entries {
+		key := entry.Key
+		if !isIdent(key) {
+			key = strconv.Quote(key)
+		}
+
+		entries[i] = key + ": " + entry.Value.format()
+	}
+
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// formatFloat formats the float so it is not parsed as an integer.
+func formatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if math.IsInf(v, 0) || math.IsNaN(v) || strings.ContainsAny(s, ".e") {
+		return s
+	}
+
+	return s + ".0"
+}
+
+// isIdent reports whether s can be written as an identifier in the expression.
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, r := range s {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && unicode.IsDigit(r) {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,160 @@
+package pl_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lesomnus/pl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlString(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		input    *pl.Pl
+		expected string
+	}{
+		{
+			desc:     "function without arguments",
+			input:    pl.NewPl(must(pl.NewFn("a"))),
+			expected: `(a)`,
+		},
+		{
+			desc:     "empty pipeline",
+			input:    pl.NewPl(),
+			expected: `()`,
+		},
+		{
+			desc:     "scalars",
+			input:    pl.NewPl(must(pl.NewFn("a", "b", 42, -36, 3.14, 2.0, 1e21, -0.5))),
+			expected: `(a "b" 42 -36 3.14 2.0 1e+21 -0.5)`,
+		},
+		{
+			desc:     "literals",
+			input:    pl.NewPl(must(pl.NewFn("a", true, false, nil))),
+			expected: `(a true false nil)`,
+		},
+		{
+			desc:     "collections",
+			input:    pl.NewPl(must(pl.NewFn("a", []any{1, "b", []any{}}, map[string]any{"c": 1.0, "d-e": map[string]any{}}))),
+			expected: `(a [1, "b", []] {c: 1.0, "d-e": {}})`,
+		},
+		{
+			desc:     "strings are quoted",
+			input:    pl.NewPl(must(pl.NewFn("a", `say "hi"`, "tab\there\nnew line", "한글"))),
+			expected: `(a "say \"hi\"" "tab\there\nnew line" "한글")`,
+		},
+		{
+			desc:     "references",
+			input:    pl.NewPl(must(pl.NewFn("a", must(pl.NewRef("b", 1, "c-1", "_d2", "3e", ""))))),
+			expected: `(a $.b[1]["c-1"]._d2["3e"][""])`,
+		},
+		{
+			desc:     "list of one item after reference",
+			input:    pl.NewPl(must(pl.NewFn("a", must(pl.NewRef("b")), []any{1}, []any{2}))),
+			expected: `(a $.b [1,] [2])`,
+		},
+		{
+			desc: "sequence of nested functions",
+			input: pl.NewPl(
+				must(pl.NewFn("a", pl.NewPl(must(pl.NewFn("b")), must(pl.NewFn("c", 1))))),
+				must(pl.NewFn("d", "e")),
+			),
+			expected: `(a (b | c 1) | d "e")`,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			expr := tc.input.String()
+			require.Equal(tc.expected, expr)
+
+			parsed, err := pl.ParseString(expr)
+			require.NoError(err)
+			require.Equal(tc.input, withoutPos(parsed))
+		})
+	}
+}
+
+func TestArgFormat(t *testing.T) {
+	require := require.New(t)
+
+	args := must(pl.NewArgs("foo", 42, must(pl.NewRef("a", 0)), pl.NewPl(must(pl.NewFn("b")))))
+	require.Equal(`"foo" 42 $.a[0] (b)`, fmt.Sprintf("%v %s %v %v", args[0], args[1], args[2], args[3]))
+}
+
+func TestPlStringRoundTrip(t *testing.T) {
+	exprs := []string{
+		`(a $.b[1]["c-1"] $[2]["d-d"][3] $.e[4][f])`,
+		`(a "b"   42 $.a[1].b 3.14 "36")`,
+		`(a "b" 42 3.14 "36"|c "d" 21)`,
+		`(a "b" (c "d" 21 | e 3.14) 37)`,
+		`(a -1 +2 -3.5 +4.5)`,
+		`(a [1,2,] {"b":$.c, d: [(e)]})`,
+		`(a 1 | b "%s" _ [_])`,
+		`(let $x 1 | a $x $x.b[0] $x["c-d"])`,
+		`(a $.b?[1]?["c-d"]? $.e?.f | g $x.y?)`,
+		`(a {x->b $x} {x,y -> c|d $y _})`,
+		`(a $.b[-1] $.c[1:3][:-2]? $[::2] $.d[:] | e $x[::-1])`,
+		`(a $.b[*].c $.*[0]? $..d $.e.."f-g" | h $x[*]..y)`,
+		`(a $.h $.b[?(.c[?(.d)])].e $[?(eq $.f "g"|ne $x 1)]?)`,
+	}
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			require := require.New(t)
+
+			p, err := pl.ParseString(expr)
+			require.NoError(err)
+
+			q, err := pl.ParseString(p.String())
+			require.NoError(err)
+			require.Equal(withoutPos(p), withoutPos(q))
+		})
+	}
+}
+
+func TestPlPretty(t *testing.T) {
+	p := must(pl.ParseString(`(regex "^v(\\d+)$" $.tags | printf "v%s" (pass "Morty" | printf "%s-%s" "Rick") | pass)`))
+
+	tcs := []struct {
+		desc     string
+		width    int
+		expected string
+	}{
+		{
+			desc:     "fit in width",
+			width:    100,
+			expected: p.String(),
+		},
+		{
+			desc:  "break pipeline",
+			width: 60,
+			expected: "" +
+				"(regex \"^v(\\\\d+)$\" $.tags\n" +
+				"\t| printf \"v%s\" (pass \"Morty\" | printf \"%s-%s\" \"Rick\")\n" +
+				"\t| pass)",
+		},
+		{
+			desc:  "break nested pipeline",
+			width: 40,
+			expected: "" +
+				"(regex \"^v(\\\\d+)$\" $.tags\n" +
+				"\t| printf \"v%s\" (pass \"Morty\"\n" +
+				"\t\t| printf \"%s-%s\" \"Rick\")\n" +
+				"\t| pass)",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			expr := p.Pretty(tc.width)
+			require.Equal(tc.expected, expr)
+
+			parsed, err := pl.ParseString(expr)
+			require.NoError(err)
+			require.Equal(withoutPos(p), withoutPos(parsed))
+		})
+	}
+}
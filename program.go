@@ -0,0 +1,747 @@
+package pl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/lesomnus/pl/funcs"
+)
+
+// Program is a pipeline compiled by an Executor.
+// Functions are resolved and their signatures are validated at compile time
+// so it can be run repeatedly against different data.
+type Program struct {
+	executor *Executor
+	root     *plNode
+
+	convs *convCache
+}
+
+type plNode struct {
+	fns []*fnNode
+}
+
+type fnNode struct {
+	name string
+	fn   *callable
+	args []*argNode
+
+	has_nested      bool
+	has_placeholder bool
+
+	// form is the name of the special form compiled by compileForm.
+	form string
+
+	// bind is the name of the variable bound by the special form `let` or `as`.
+	bind string
+
+	path   []Frame
+	pos    lexer.Position
+	endPos lexer.Position
+}
+
+type argNode struct {
+	value  any
+	ref    Ref
+	nested *plNode
+
+	// filters are predicates of the filters in `ref`.
+	filters map[*Filter]*plNode
+
+	// var_name is the name of the variable bound in the scope.
+	// `ref` is the path from the variable if any.
+	var_name string
+
+	is_placeholder bool
+
+	// is_thunk denotes that the nested pipeline is given as a Thunk.
+	is_thunk bool
+
+	lambda *lambdaNode
+
+	// Elements of a list or map literal.
+	// `keys` is not nil only for a map literal.
+	items []*argNode
+	keys  []string
+
+	pos    lexer.Position
+	endPos lexer.Position
+}
+
+type lambdaNode struct {
+	params []string
+	body   *plNode
+}
+
+// isConst reports whether the value of the argument is known at compile time.
+func (n *argNode) isConst() bool {
+	return n.ref == nil && n.nested == nil && n.items == nil && !n.is_placeholder && n.var_name == "" && n.lambda == nil
+}
+
+func (n *argNode) hasPlaceholder() bool {
+	if n.is_placeholder {
+		return true
+	}
+	for _, item := range n.items {
+		if item.hasPlaceholder() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fail creates an error caused by the function.
+// `i` is the index of the argument in the expression that caused the error, or -1.
+func (n *fnNode) fail(i int, err error) *ExecError {
+	path := make([]Frame, len(n.path))
+	copy(path, n.path)
+	path[len(path)-1].Arg = i
+
+	pos, end_pos := n.pos, n.endPos
+	if i >= 0 && i < len(n.args) && n.args[i] != nil {
+		pos, end_pos = n.args[i].pos, n.args[i].endPos
+	}
+
+	return &ExecError{
+		Path:   path,
+		Name:   n.name,
+		Arg:    i,
+		Pos:    pos,
+		EndPos: end_pos,
+		Err:    err,
+	}
+}
+
+// Compile resolves functions used in the given pipeline and checks if they can be invoked
+// with given arguments.
+// The returned error is an *ExecError.
+func (e *Executor) Compile(pl *Pl) (*Program, error) {
+	prog := &Program{executor: e, convs: newConvCache(e.Convs, e.Strict)}
+
+	root, err := prog.compilePl(pl, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	prog.root = root
+	return prog, nil
+}
+
+// compilePl compiles a pipeline.
+// `path` is frames to the argument where the pipeline is nested.
+// `vars` is variables bound in the enclosing pipelines; values are not used.
+func (p *Program) compilePl(pl *Pl, path []Frame, vars *scope) (*plNode, error) {
+	rst := &plNode{fns: make([]*fnNode, len(pl.Funcs))}
+	for i, fn := range pl.Funcs {
+		fn_path := make([]Frame, len(path), len(path)+1)
+		copy(fn_path, path)
+		fn_path = append(fn_path, Frame{Fn: i, Name: fn.Name, Arg: -1})
+
+		node, err := p.compileFn(fn, fn_path, vars, i > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		rst.fns[i] = node
+		if node.bind != "" {
+			vars = vars.with(node.bind, nil)
+		}
+	}
+
+	return rst, nil
+}
+
+// compileFn compiles a function in the pipeline.
+// `is_piped` denotes that the function takes results of the previous function.
+func (p *Program) compileFn(fn *Fn, path []Frame, vars *scope, is_piped bool) (*fnNode, error) {
+	rst := &fnNode{
+		name: fn.Name,
+		args: make([]*argNode, len(fn.Args)),
+
+		path:   path,
+		pos:    fn.Pos,
+		endPos: fn.EndPos,
+	}
+
+	switch fn.Name {
+	case "let", "as":
+		return p.compileBind(rst, fn, vars, is_piped)
+	case "if", "and", "or", "coalesce", "default":
+		return p.compileForm(rst, fn, vars, is_piped)
+	}
+
+	f, ok := p.executor.Funcs[fn.Name]
+	if !ok {
+		// Mark only the name of the function.
+		err := rst.fail(-1, fmt.Errorf("function %q %w", fn.Name, ErrUndefinedFunc))
+		err.EndPos = fn.Pos
+		err.EndPos.Advance(fn.Name)
+		return nil, err
+	}
+
+	c, err := newCallable(f)
+	if err != nil {
+		return nil, rst.fail(-1, err)
+	}
+
+	rst.fn = c
+	if err := p.compileArgs(rst, fn, vars, is_piped); err != nil {
+		return nil, err
+	}
+
+	// Nested pipelines at the position of Thunk parameters are not spread.
+	// The position is known only before any arguments spread,
+	// so a nested pipeline after them fails if it may be given to a Thunk parameter.
+	rst.has_nested = false
+	is_fixed := true
+	pos := 0 // Position of the argument, or the minimum position if it is not fixed.
+	for i, node := range rst.args {
+		if node.is_placeholder || node.ref.isMulti() {
+			is_fixed = false
+			rst.has_nested = true
+			continue
+		}
+		if node.nested == nil {
+			pos++
+			continue
+		}
+		if is_fixed && c.takesThunk(pos, false) {
+			node.is_thunk = true
+			pos++
+			continue
+		}
+		if !is_fixed && c.takesThunk(pos, true) {
+			return nil, rst.fail(i, errors.New("nested pipeline may be given to a Thunk parameter but its position is not fixed as arguments before it are spread"))
+		}
+
+		is_fixed = false
+		rst.has_nested = true
+	}
+
+	// Number of arguments is exact only if there are no arguments spread.
+	// Arguments spread can give no values, so only the others are counted.
+	if err := c.checkArity(pos, !(is_piped || rst.has_nested)); err != nil {
+		return nil, rst.fail(-1, err)
+	}
+
+	// Constants before any nested pipeline have static position
+	// so they can be converted into the parameter type in advance.
+	for i, node := range rst.args {
+		if (node.nested != nil && !node.is_thunk) || node.is_placeholder || node.ref.isMulti() {
+			break
+		}
+		if !node.isConst() {
+			continue
+		}
+
+		v, err := c.convertArg(i, node.value, p.convs.find)
+		if err != nil {
+			return nil, rst.fail(i, errors.Unwrap(err))
+		}
+
+		node.value = v.Interface()
+	}
+
+	return rst, nil
+}
+
+// compileArgs compiles arguments of the function into `rst.args`.
+func (p *Program) compileArgs(rst *fnNode, fn *Fn, vars *scope, is_piped bool) error {
+	for i, arg := range fn.Args {
+		nested_path := make([]Frame, len(rst.path))
+		copy(nested_path, rst.path)
+		nested_path[len(rst.path)-1].Arg = i
+
+		node, err := p.compileArg(arg, nested_path, vars)
+		if err != nil {
+			if _, ok := err.(*ExecError); ok {
+				return err
+			}
+
+			err := rst.fail(i, err)
+			err.Pos, err.EndPos = arg.Pos, arg.EndPos
+			return err
+		}
+
+		rst.args[i] = node
+		if node.nested != nil || node.ref.isMulti() {
+			rst.has_nested = true
+		}
+		if node.hasPlaceholder() {
+			if !is_piped {
+				return rst.fail(i, errors.New("placeholder is used in the first function of the pipeline"))
+			}
+
+			rst.has_placeholder = true
+		}
+	}
+
+	return nil
+}
+
+// compileArg compiles an argument or an element of a literal in the argument.
+// `path` is frames to the argument.
+func (p *Program) compileArg(arg *Arg, path []Frame, vars *scope) (*argNode, error) {
+	node := &argNode{pos: arg.Pos, endPos: arg.EndPos}
+
+	if arg.String != nil {
+		node.value = *arg.String
+	} else if arg.Float != nil {
+		node.value = *arg.Float
+	} else if arg.Int != nil {
+		node.value = *arg.Int
+	} else if arg.Bool != nil {
+		node.value = bool(*arg.Bool)
+	} else if arg.Null {
+		node.value = nil
+	} else if arg.Placeholder {
+		node.is_placeholder = true
+	} else if arg.Var != nil {
+		// A variable that is not bound refers to the field of the data.
+		if _, ok := vars.lookup(*arg.Var); ok {
+			node.var_name = *arg.Var
+			node.ref = arg.Ref
+		} else {
+			node.ref = append(Ref{{Name: arg.Var}}, arg.Ref...)
+		}
+	} else if arg.Ref != nil {
+		node.ref = arg.Ref
+	} else if arg.Root {
+		node.ref = Ref{}
+	} else if arg.Nested != nil {
+		nested, err := p.compilePl(arg.Nested, path, vars)
+		if err != nil {
+			return nil, err
+		}
+
+		node.nested = nested
+	} else if arg.Lambda != nil {
+		body_vars := vars
+		for _, param := range arg.Lambda.Params {
+			body_vars = body_vars.with(param, nil)
+		}
+
+		body, err := p.compilePl(&Pl{Funcs: arg.Lambda.Funcs}, path, body_vars)
+		if err != nil {
+			return nil, err
+		}
+
+		node.lambda = &lambdaNode{params: arg.Lambda.Params, body: body}
+	} else if arg.List != nil {
+		node.items = make([]*argNode, len(arg.List.Items))
+		for i, item := range arg.List.Items {
+			item_node, err := p.compileArg(item, path, vars)
+			if err != nil {
+				return nil, err
+			}
+
+			node.items[i] = item_node
+		}
+	} else if arg.Map != nil {
+		node.items = make([]*argNode, len(arg.Map.Entries))
+		node.keys = make([]string, len(arg.Map.Entries))
+		for i, entry := range arg.Map.Entries {
+			if entry.Value == nil {
+				return nil, fmt.Errorf("empty value for key %q", entry.Key)
+			}
+
+			item_node, err := p.compileArg(entry.Value, path, vars)
+			if err != nil {
+				return nil, err
+			}
+
+			node.items[i] = item_node
+			node.keys[i] = entry.Key
+		}
+	} else {
+		return nil, errors.New("empty value")
+	}
+
+	if node.ref != nil {
+		filters := map[*Filter]*plNode{}
+		if err := p.compileFilters(node.ref, path, vars, filters); err != nil {
+			return nil, err
+		}
+		if len(filters) > 0 {
+			node.filters = filters
+		}
+	}
+
+	return node, nil
+}
+
+// compileFilters compiles predicates of the filters in the reference into `rst`.
+// `path` is frames to the argument where the reference is.
+func (p *Program) compileFilters(ref Ref, path []Frame, vars *scope, rst map[*Filter]*plNode) error {
+	for _, key := range ref {
+		if key.Filter == nil {
+			continue
+		}
+		if key.Filter.Pl == nil {
+			if err := p.compileFilters(key.Filter.Ref, path, vars, rst); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		pl, err := p.compilePl(key.Filter.Pl, path, vars)
+		if err != nil {
+			return err
+		}
+
+		rst[key.Filter] = pl
+	}
+
+	return nil
+}
+
+// Run executes the compiled pipeline with given data.
+// The returned error is an *ExecError.
+func (p *Program) Run(data any) ([]any, error) {
+	return p.RunContext(context.Background(), data)
+}
+
+// RunContext executes the compiled pipeline with given data.
+// The execution stops with the context's error if the context is done
+// before invoking each function or evaluating each nested pipeline.
+func (p *Program) RunContext(ctx context.Context, data any) ([]any, error) {
+	return p.runPl(ctx, p.root, env{data: data})
+}
+
+// env is the environment where the pipeline runs.
+type env struct {
+	data any
+	vars *scope
+}
+
+func (p *Program) runPl(ctx context.Context, pl *plNode, e env) ([]any, error) {
+	args_prev := []any{}
+	for _, fn := range pl.fns {
+		if fn.bind != "" {
+			v, err := p.runBind(ctx, fn, e, args_prev)
+			if err != nil {
+				return nil, err
+			}
+
+			e.vars = e.vars.with(fn.bind, v)
+			continue
+		}
+		if fn.form != "" {
+			rst, err := p.runForm(ctx, fn, e, args_prev)
+			if err != nil {
+				return nil, err
+			}
+
+			args_prev = rst
+			continue
+		}
+
+		rst, err := p.runFn(ctx, fn, e, args_prev)
+		if err != nil {
+			return nil, err
+		}
+
+		if fn.fn.no_spread {
+			args_prev = []any{rst}
+		} else {
+			args_prev = spread(rst)
+		}
+	}
+
+	return args_prev, nil
+}
+
+func (p *Program) runFn(ctx context.Context, fn *fnNode, e env, args_prev []any) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fn.fail(-1, err)
+	}
+
+	args, origins, err := p.evaluateArgs(ctx, fn, e, args_prev)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fn.fail(-1, err)
+	}
+
+	// Results of the previous function are appended if they are not placed by the placeholder.
+	if !fn.has_placeholder {
+		args = append(args, args_prev...)
+	}
+	rst, err := fn.fn.invoke(ctx, args, p.convs.find)
+	if p.executor.Trace != nil {
+		p.executor.Trace(fn.path, args, rst, err)
+	}
+	if err == nil {
+		return rst, nil
+	}
+
+	if err, ok := err.(*argError); ok {
+		i := len(fn.args)
+		if err.index < len(origins) {
+			i = origins[err.index]
+		}
+
+		return nil, fn.fail(i, err.err)
+	}
+
+	// The error from a Thunk already has where it occurred.
+	if err, ok := err.(*ExecError); ok {
+		return nil, err
+	}
+
+	return nil, fn.fail(-1, err)
+}
+
+// evaluateArgs evaluates arguments of the function.
+// `args_prev` are results of the previous function that are spliced at the placeholders.
+// It also returns the index of the argument in the expression where each value comes from.
+func (p *Program) evaluateArgs(ctx context.Context, fn *fnNode, e env, args_prev []any) ([]any, []int, error) {
+	rst := make([]any, 0, len(fn.args))
+	origins := make([]int, 0, len(fn.args))
+	for i, arg := range fn.args {
+		vs, err := p.evaluateSpread(ctx, fn, i, arg, e, args_prev)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rst = append(rst, vs...)
+		for len(origins) < len(rst) {
+			origins = append(origins, i)
+		}
+	}
+
+	return rst, origins, nil
+}
+
+// evaluateSpread evaluates a value in i-th argument of the function into values
+// that are spread at the position of the value.
+// Only results of a nested pipeline and values at the placeholder can be more than one.
+func (p *Program) evaluateSpread(ctx context.Context, fn *fnNode, i int, arg *argNode, e env, args_prev []any) ([]any, error) {
+	if arg.is_thunk {
+		return []any{Thunk(func() ([]any, error) {
+			return p.runPl(ctx, arg.nested, e)
+		})}, nil
+	}
+	if arg.nested != nil {
+		return p.runPl(ctx, arg.nested, e)
+	}
+	if arg.is_placeholder {
+		return args_prev, nil
+	}
+	if arg.ref.isMulti() {
+		return p.resolveArg(ctx, fn, i, arg, e)
+	}
+
+	v, err := p.evaluateArg(ctx, fn, i, arg, e, args_prev)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{v}, nil
+}
+
+// evaluateArg evaluates a value in i-th argument of the function.
+// Values that can be spread are spread in a list literal
+// but they are a slice in a map literal unless there is exactly one value.
+func (p *Program) evaluateArg(ctx context.Context, fn *fnNode, i int, arg *argNode, e env, args_prev []any) (any, error) {
+	if arg.nested != nil || arg.is_placeholder {
+		vs, err := p.evaluateSpread(ctx, fn, i, arg, e, args_prev)
+		if err != nil {
+			return nil, err
+		}
+		if len(vs) == 1 {
+			return vs[0], nil
+		}
+
+		return vs, nil
+	}
+	if arg.var_name != "" || arg.ref != nil {
+		vs, err := p.resolveArg(ctx, fn, i, arg, e)
+		if err != nil {
+			return nil, err
+		}
+		return collapse(arg.ref, vs), nil
+	}
+	if arg.lambda != nil {
+		return p.makeLambda(ctx, arg.lambda, e), nil
+	}
+	if arg.items == nil {
+		return arg.value, nil
+	}
+
+	// Elements are evaluated on every run so the function cannot modify
+	// the literal of the other runs.
+	if arg.keys != nil {
+		rst := make(map[string]any, len(arg.items))
+		for j, item := range arg.items {
+			v, err := p.evaluateArg(ctx, fn, i, item, e, args_prev)
+			if err != nil {
+				return nil, err
+			}
+
+			rst[arg.keys[j]] = v
+		}
+
+		return rst, nil
+	}
+
+	rst := make([]any, 0, len(arg.items))
+	for _, item := range arg.items {
+		vs, err := p.evaluateSpread(ctx, fn, i, item, e, args_prev)
+		if err != nil {
+			return nil, err
+		}
+
+		rst = append(rst, vs...)
+	}
+
+	return rst, nil
+}
+
+// resolveArg resolves the reference or the variable in i-th argument of the function.
+func (p *Program) resolveArg(ctx context.Context, fn *fnNode, i int, arg *argNode, e env) ([]any, error) {
+	data := e.data
+	if arg.var_name != "" {
+		data, _ = e.vars.lookup(arg.var_name)
+		if len(arg.ref) == 0 {
+			return []any{data}, nil
+		}
+	}
+
+	vs, err := resolveAll(data, arg.ref, p.filterTest(ctx, arg.filters, e))
+	if err != nil {
+		// The error from a predicate already has where it occurred.
+		if err, ok := err.(*ExecError); ok {
+			return nil, err
+		}
+		if arg.var_name != "" {
+			err = fmt.Errorf("$%s: %w", arg.var_name, err)
+		}
+
+		err := fn.fail(i, err)
+		err.Ref = arg.ref
+		err.Pos, err.EndPos = arg.pos, arg.endPos
+		return nil, err
+	}
+
+	return vs, nil
+}
+
+// filterTest returns a function that tests values by the predicates compiled in `filters`.
+// A predicate that is a pipeline runs with the value as its data and the variables in `e`.
+// A predicate that is a reference is false if the reference is not found
+// or its key does not apply to the value, such as a name to an integer.
+func (p *Program) filterTest(ctx context.Context, filters map[*Filter]*plNode, e env) filterTest {
+	var test filterTest
+	test = func(f *Filter, v any) (bool, error) {
+		if f.Pl == nil {
+			vs, err := resolveAll(v, f.Ref, test)
+			var kind_err *kindError
+			if errors.Is(err, ErrRefNotFound) || errors.As(err, &kind_err) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+
+			return IsTrue(collapse(f.Ref, vs)), nil
+		}
+
+		pl, ok := filters[f]
+		if !ok {
+			return false, errors.New("filter is not compiled")
+		}
+
+		vs, err := p.runPl(ctx, pl, env{data: v, vars: e.vars})
+		if err != nil {
+			return false, err
+		}
+		if len(vs) == 1 {
+			return IsTrue(vs[0]), nil
+		}
+
+		return IsTrue(vs), nil
+	}
+
+	return test
+}
+
+// makeLambda makes a function that runs the body of the lambda
+// with the arguments bound to its parameters.
+func (p *Program) makeLambda(ctx context.Context, l *lambdaNode, e env) funcs.Lambda {
+	return func(args ...any) ([]any, error) {
+		if len(args) != len(l.params) {
+			return nil, fmt.Errorf("%w: lambda takes %d args but %d args are given", ErrArity, len(l.params), len(args))
+		}
+
+		body_env := e
+		for i, param := range l.params {
+			body_env.vars = body_env.vars.with(param, args[i])
+		}
+
+		return p.runPl(ctx, l.body, body_env)
+	}
+}
+
+// spread makes a result of a function to be arguments of the next function.
+// A slice is spread into its elements unless it is wrapped by Single.
+func spread(v any) []any {
+	switch v := v.(type) {
+	case single:
+		return []any{v.v}
+	case many:
+		return v.vs
+	}
+	if v == nil || reflect.TypeOf(v).Kind() != reflect.Slice {
+		return []any{v}
+	}
+
+	vs := reflect.ValueOf(v)
+	rst := make([]any, vs.Len())
+	for i := 0; i < vs.Len(); i++ {
+		rst[i] = vs.Index(i).Interface()
+	}
+
+	return rst
+}
+
+type convKey struct {
+	out reflect.Type
+	in  reflect.Type
+}
+
+// convCache memoizes conversions found in the ConvMap for a program.
+// Failures are not memoized, so a conversion set in the ConvMap afterward is found.
+type convCache struct {
+	convs  ConvMap
+	strict bool
+
+	entries sync.Map
+}
+
+func newConvCache(convs ConvMap, strict bool) *convCache {
+	return &convCache{convs: convs, strict: strict}
+}
+
+func (c *convCache) find(out reflect.Type, in reflect.Type) (func(v reflect.Value) (any, error), error) {
+	key := convKey{out: out, in: in}
+	if conv, ok := c.entries.Load(key); ok {
+		return conv.(func(v reflect.Value) (any, error)), nil
+	}
+
+	conv, err := c.convs.find(out, in, c.strict)
+	if err != nil {
+		return nil, err
+	}
+
+	c.entries.Store(key, conv)
+	return conv, nil
+}
@@ -0,0 +1,133 @@
+package pl_test
+
+import (
+	"testing"
+
+	"github.com/lesomnus/pl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramRun(t *testing.T) {
+	require := require.New(t)
+
+	executor := pl.NewExecutor()
+	executor.Funcs["add"] = func(lhs int, rhs int) int { return lhs + rhs }
+
+	prog, err := executor.Compile(must(pl.ParseString(`(add 1 $.A | printf "%s-%v" "v")`)))
+	require.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		rst, err := prog.Run(struct{ A int }{A: i})
+		require.NoError(err)
+		require.Equal([]any{"v-" + []string{"1", "2", "3"}[i]}, rst)
+	}
+}
+
+func TestExecutorCompile(t *testing.T) {
+	executor := pl.Executor{
+		Funcs: map[string]any{
+			"one": func(v int) int { return v },
+			"two": func(lhs int, rhs int) int { return lhs + rhs },
+			"sum": func(lhs int, vs ...int) int { return lhs },
+			"nop": func() {},
+			"num": 42,
+		},
+	}
+
+	tcs := []struct {
+		desc string
+		expr string
+		msgs []string
+	}{
+		{
+			desc: "function is not defined",
+			expr: `(one 1 | Slurm)`,
+			msgs: []string{"fn[1]", "Slurm", "not defined"},
+		},
+		{
+			desc: "nested function is not defined",
+			expr: `(two 1 (Slurm))`,
+			msgs: []string{"fn[0]", "two", "arg[1]", "Slurm", "not defined"},
+		},
+		{
+			desc: "value is not a function",
+			expr: `(num)`,
+			msgs: []string{"expected a function"},
+		},
+		{
+			desc: "function returns nothing",
+			expr: `(nop)`,
+			msgs: []string{"one or two"},
+		},
+		{
+			desc: "too few arguments",
+			expr: `(two 1)`,
+			msgs: []string{"expected 2 args but 1 args are given"},
+		},
+		{
+			desc: "too few arguments to variadic function",
+			expr: `(sum)`,
+			msgs: []string{"at least 1 args"},
+		},
+		{
+			desc: "too many arguments",
+			expr: `(one 1 2)`,
+			msgs: []string{"expected 1 args but at least 2 args are given"},
+		},
+		{
+			desc: "too many arguments with piped values",
+			expr: `(one 1 | one 2 3)`,
+			msgs: []string{"fn[1]", "at least 2 args"},
+		},
+		{
+			desc: "function in list literal is not defined",
+			expr: `(one [1, {a: (Slurm)}])`,
+			msgs: []string{"fn[0]", "one", "arg[0]", "Slurm", "not defined"},
+		},
+		{
+			desc: "constant cannot be converted",
+			expr: `(one "Rick")`,
+			msgs: []string{"arg[0]", "convert to int from string"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			_, err := executor.Compile(must(pl.ParseString(tc.expr)))
+			for _, msg := range tc.msgs {
+				require.ErrorContains(err, msg)
+			}
+		})
+	}
+
+	t.Run("number of arguments is not checked if there are spread arguments", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := executor.Compile(must(pl.ParseString(`(one (two 1 2))`)))
+		require.NoError(err)
+
+		_, err = executor.Compile(must(pl.ParseString(`(one 1 | one)`)))
+		require.NoError(err)
+
+		_, err = executor.Compile(must(pl.ParseString(`(one (two 1 2) 1)`)))
+		require.NoError(err)
+	})
+
+	t.Run("spread arguments can give no values", func(t *testing.T) {
+		require := require.New(t)
+
+		executor := pl.NewExecutor()
+		executor.Funcs["one"] = func(v int) int { return v }
+
+		for _, expr := range []string{
+			`(one (pass) 1)`,
+			`(one (if false 2) 1)`,
+			`(one $.a[*] 1)`,
+		} {
+			rst, err := executor.ExecuteExpr(expr, map[string]any{"a": []any{}})
+			require.NoError(err, expr)
+			require.Equal([]any{1}, rst, expr)
+		}
+	})
+}
@@ -1,9 +1,12 @@
 package pl
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 type Ref []RefKey
@@ -17,9 +20,66 @@ func (r Ref) String() string {
 	return strings.Join(paths, "")
 }
 
+// isMulti reports whether the reference can refer to multiple values.
+func (r Ref) isMulti() bool {
+	for _, k := range r {
+		if k.Wildcard || k.Descent != nil || k.Filter != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve resolves the reference against the data.
+// If the reference has wildcards, recursive descents, or filters, it gives the values as a `[]any`.
+// Filters are evaluated only by Executor.Resolve.
 func Resolve(data any, ref Ref) (any, error) {
-	cursor := reflect.ValueOf(data)
-	for i, key := range ref {
+	vs, err := ResolveAll(data, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return collapse(ref, vs), nil
+}
+
+// ResolveAll resolves the reference against the data and gives all values it refers to.
+// Wildcards visit elements of lists, values of maps in order of their keys, and exported fields of structs,
+// and recursive descents visit the values and all of their descendants in the same order.
+func ResolveAll(data any, ref Ref) ([]any, error) {
+	return resolveAll(data, ref, nil)
+}
+
+// filterTest reports whether the value satisfies the predicate of the filter.
+type filterTest func(f *Filter, v any) (bool, error)
+
+func resolveAll(data any, ref Ref, test filterTest) ([]any, error) {
+	rst := []any{}
+	if err := resolve(reflect.ValueOf(data), ref, 0, test, func(v any) { rst = append(rst, v) }); err != nil {
+		return nil, err
+	}
+
+	return rst, nil
+}
+
+// collapse gives the value resolved by the reference that cannot refer to multiple values.
+func collapse(ref Ref, vs []any) any {
+	if ref.isMulti() {
+		return vs
+	}
+
+	return vs[0]
+}
+
+// resolve resolves keys of the reference from `begin` and yields the values.
+// Filters fail if `test` is nil.
+func resolve(cursor reflect.Value, ref Ref, begin int, test filterTest, yield func(v any)) error {
+	for i := begin; i < len(ref); i++ {
+		key := ref[i]
+		if !cursor.IsValid() {
+			return missing(ref[:i], yield, fmt.Errorf("$%s is nil: %w", ref[:i].String(), ErrRefNotFound))
+		}
+
 		t := cursor.Type()
 		for {
 			switch t.Kind() {
@@ -27,6 +87,10 @@ func Resolve(data any, ref Ref) (any, error) {
 				fallthrough
 			case reflect.Interface:
 				cursor = cursor.Elem()
+				if !cursor.IsValid() {
+					return missing(ref[:i], yield, fmt.Errorf("$%s is nil: %w", ref[:i].String(), ErrRefNotFound))
+				}
+
 				t = cursor.Type()
 				continue
 			}
@@ -38,22 +102,22 @@ func Resolve(data any, ref Ref) (any, error) {
 			switch t.Kind() {
 			case reflect.Map:
 				if t.Key().Kind() != reflect.String {
-					return nil, fmt.Errorf("%s is a map but key type is not a string", ref[:i].String())
+					return kindErrorf("$%s is a map but key type is not a string", ref[:i].String())
 				}
 
 				cursor = cursor.MapIndex(reflect.ValueOf(*key.Name))
 				if !cursor.IsValid() {
-					return nil, fmt.Errorf("$%s has no key %s", ref[:i].String(), *key.Name)
+					return missing(ref[:i+1], yield, fmt.Errorf("$%s has no key %s: %w", ref[:i].String(), *key.Name, ErrRefNotFound))
 				}
 
 			case reflect.Struct:
 				cursor = cursor.FieldByName(*key.Name)
 				if !cursor.IsValid() {
-					return nil, fmt.Errorf("$%s has no field %s", ref[:i].String(), *key.Name)
+					return missing(ref[:i+1], yield, fmt.Errorf("$%s has no field %s: %w", ref[:i].String(), *key.Name, ErrRefNotFound))
 				}
 
 			default:
-				return nil, fmt.Errorf("$%s is not an object but %s", ref[:i].String(), t.String())
+				return kindErrorf("$%s is not an object but %s", ref[:i].String(), t.String())
 			}
 		} else if key.Index != nil {
 			switch t.Kind() {
@@ -78,33 +142,319 @@ func Resolve(data any, ref Ref) (any, error) {
 					index = uint64(*key.Index)
 
 				default:
-					return nil, fmt.Errorf("%s is a map but key type is not an integer", ref[:i].String())
+					return kindErrorf("$%s is a map but key type is not an integer", ref[:i].String())
 				}
 
 				cursor = cursor.MapIndex(reflect.ValueOf(index))
 				if !cursor.IsValid() {
-					return nil, fmt.Errorf("$%s has no key %d", ref[:i].String(), *key.Index)
+					return missing(ref[:i+1], yield, fmt.Errorf("$%s has no key %d: %w", ref[:i].String(), *key.Index, ErrRefNotFound))
 				}
 
 				continue
 
 			case reflect.Array:
 			case reflect.Slice:
+			case reflect.String:
+
+			default:
+				return kindErrorf("$%s is not a list but %s", ref[:i].String(), t.String())
+			}
+
+			index := *key.Index
+			l := lenOf(cursor)
+			if index < 0 {
+				index += l
+			}
+			if index < 0 || l <= index {
+				return missing(ref[:i+1], yield, fmt.Errorf("$%s: out of range: %w", ref[:i].String(), ErrRefNotFound))
+			}
+
+			if t.Kind() == reflect.String {
+				cursor = reflect.ValueOf(string([]rune(cursor.String())[index]))
+			} else {
+				cursor = cursor.Index(index)
+			}
+		} else if key.Slice != nil {
+			switch t.Kind() {
+			case reflect.Array:
+			case reflect.Slice:
+			case reflect.String:
+
+			default:
+				return kindErrorf("$%s is not a list but %s", ref[:i].String(), t.String())
+			}
+
+			v, err := sliceOf(cursor, key.Slice)
+			if err != nil {
+				return fmt.Errorf("$%s%s: %w", ref[:i].String(), key.Slice.String(), err)
+			}
+
+			cursor = v
+		} else if key.Filter != nil {
+			switch t.Kind() {
+			case reflect.Array:
+			case reflect.Slice:
+			case reflect.Map:
 
 			default:
-				return nil, fmt.Errorf("$%s is not a list but %s", ref[:i].String(), t.String())
+				return kindErrorf("$%s is not a list or a map but %s", ref[:i].String(), t.String())
+			}
+			if test == nil {
+				return fmt.Errorf("$%s%s: filter is evaluated only by an executor", ref[:i].String(), key.Filter.String())
 			}
 
-			l := cursor.Len()
-			if l <= *key.Index {
-				return nil, fmt.Errorf("$%s: out of range", ref[:i].String())
+			vs, _ := elemsOf(cursor)
+			for _, v := range vs {
+				var u any
+				if v.IsValid() {
+					u = v.Interface()
+				}
+
+				ok, err := test(key.Filter, u)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+
+				if err := resolve(v, ref, i+1, test, yield); err != nil {
+					return err
+				}
 			}
 
-			cursor = cursor.Index(*key.Index)
+			return nil
+		} else if key.Wildcard {
+			vs, ok := elemsOf(cursor)
+			if !ok {
+				return kindErrorf("$%s is not a list or an object but %s", ref[:i].String(), t.String())
+			}
+
+			for _, v := range vs {
+				if err := resolve(v, ref, i+1, test, yield); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		} else if key.Descent != nil {
+			vs := []reflect.Value{}
+			descend(cursor, *key.Descent, []uintptr{}, func(v reflect.Value) { vs = append(vs, v) })
+			for _, v := range vs {
+				if err := resolve(v, ref, i+1, test, yield); err != nil {
+					return err
+				}
+			}
+
+			return nil
 		} else {
-			return nil, fmt.Errorf("invalid key at %d", i)
+			return fmt.Errorf("invalid key at %d", i)
+		}
+	}
+
+	if !cursor.IsValid() {
+		yield(nil)
+		return nil
+	}
+
+	yield(cursor.Interface())
+	return nil
+}
+
+// missing yields nil instead of returning the error if the last key of the reference is optional.
+// kindError tells that a key of the reference does not apply to the kind of the value,
+// such as a name to a list.
+type kindError struct {
+	msg string
+}
+
+func kindErrorf(format string, args ...any) error {
+	return &kindError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func missing(ref Ref, yield func(v any), err error) error {
+	if len(ref) > 0 && ref[len(ref)-1].Optional {
+		yield(nil)
+		return nil
+	}
+
+	return err
+}
+
+// lenOf returns the number of elements of the list or characters of the string.
+func lenOf(v reflect.Value) int {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String())
+	}
+
+	return v.Len()
+}
+
+// sliceOf returns a slice of elements of the list or a string of characters of the string in the range.
+func sliceOf(v reflect.Value, s *Slice) (reflect.Value, error) {
+	step := 1
+	if s.Step != nil {
+		step = *s.Step
+	}
+	if step == 0 {
+		return reflect.Value{}, errors.New("slice step cannot be zero")
+	}
+
+	l := lenOf(v)
+	lo, hi := 0, l
+	if step < 0 {
+		lo, hi = -1, l-1
+	}
+
+	bound := func(x *int, d int) int {
+		if x == nil {
+			return d
+		}
+
+		i := *x
+		if i < 0 {
+			i += l
+		}
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+
+		return i
+	}
+
+	start, end := bound(s.Start, lo), bound(s.End, hi)
+	if step < 0 {
+		start, end = bound(s.Start, hi), bound(s.End, lo)
+	}
+
+	in := func(i int) bool {
+		if step > 0 {
+			return i < end
+		}
+
+		return i > end
+	}
+
+	if v.Kind() == reflect.String {
+		runes := []rune(v.String())
+		rst := []rune{}
+		for i := start; in(i); i += step {
+			rst = append(rst, runes[i])
+		}
+
+		return reflect.ValueOf(string(rst)), nil
+	}
+
+	rst := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, 0)
+	for i := start; in(i); i += step {
+		rst = reflect.Append(rst, v.Index(i))
+	}
+
+	return rst, nil
+}
+
+// elemsOf returns elements of the list, values of the map in order of their keys,
+// or exported fields of the struct.
+func elemsOf(v reflect.Value) ([]reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		rst := make([]reflect.Value, v.Len())
+		for i := range rst {
+			rst[i] = v.Index(i)
+		}
+
+		return rst, true
+
+	case reflect.Map:
+		keys := sortedKeys(v)
+		rst := make([]reflect.Value, len(keys))
+		for i, k := range keys {
+			rst[i] = v.MapIndex(k)
+		}
+
+		return rst, true
+
+	case reflect.Struct:
+		rst := []reflect.Value{}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				rst = append(rst, v.Field(i))
+			}
 		}
+
+		return rst, true
 	}
 
-	return cursor.Interface(), nil
+	return nil, false
+}
+
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		l, r := keys[i], keys[j]
+		switch l.Kind() {
+		case reflect.String:
+			return l.String() < r.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return l.Int() < r.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return l.Uint() < r.Uint()
+		case reflect.Float32, reflect.Float64:
+			return l.Float() < r.Float()
+		}
+
+		return fmt.Sprint(l.Interface()) < fmt.Sprint(r.Interface())
+	})
+
+	return keys
+}
+
+// descend yields values of the key or the field of the name in the value and all of its descendants.
+// A value is yielded before its descendants.
+// `parents` are pointers being visited to stop at a cycle.
+func descend(v reflect.Value, name string, parents []uintptr, yield func(v reflect.Value)) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		if v.Kind() == reflect.Pointer {
+			for _, p := range parents {
+				if p == v.Pointer() {
+					return
+				}
+			}
+
+			parents = append(parents, v.Pointer())
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			if u := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); u.IsValid() {
+				yield(u)
+			}
+		}
+
+	case reflect.Struct:
+		if f, ok := v.Type().FieldByName(name); ok && f.IsExported() {
+			if u, err := v.FieldByIndexErr(f.Index); err == nil {
+				yield(u)
+			}
+		}
+	}
+
+	vs, _ := elemsOf(v)
+	for _, u := range vs {
+		descend(u, name, parents, yield)
+	}
 }
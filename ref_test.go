@@ -18,6 +18,28 @@ func TestReString(t *testing.T) {
 
 	path := ref.String()
 	require.Equal(".foo[42].?", path)
+
+	ref = pl.Ref{
+		{Name: addr("foo"), Optional: true},
+		{Name: addr("a-b"), Optional: true},
+		{Index: addr(42), Optional: true},
+	}
+	require.Equal(`.foo?["a-b"]?[42]?`, ref.String())
+
+	ref = pl.Ref{
+		{Index: addr(-1)},
+		{Slice: &pl.Slice{Start: addr(1), End: addr(-1)}},
+		{Slice: &pl.Slice{}},
+		{Slice: &pl.Slice{Step: addr(2)}, Optional: true},
+	}
+	require.Equal(`[-1][1:-1][:][::2]?`, ref.String())
+
+	ref = pl.Ref{
+		{Wildcard: true},
+		{Descent: addr("foo")},
+		{Descent: addr("a-b"), Optional: true},
+	}
+	require.Equal(`[*]..foo.."a-b"?`, ref.String())
 }
 
 func TestResolve(t *testing.T) {
@@ -135,6 +157,168 @@ func TestResolve(t *testing.T) {
 			ref:      must(pl.NewRef("A", 0, "b", 42, "Pi")),
 			expected: 3.14,
 		},
+		{
+			desc:     "nil value",
+			input:    map[string]any{"a": nil},
+			ref:      must(pl.NewRef("a")),
+			expected: nil,
+		},
+		{
+			desc:     "negative index",
+			input:    []string{"foo", "bar", "baz"},
+			ref:      must(pl.NewRef(-1)),
+			expected: "baz",
+		},
+		{
+			desc:     "index of string",
+			input:    "한글",
+			ref:      must(pl.NewRef(-1)),
+			expected: "글",
+		},
+		{
+			desc:     "slice",
+			input:    []string{"foo", "bar", "baz"},
+			ref:      must(pl.NewRef(pl.Slice{Start: addr(1), End: addr(3)})),
+			expected: []string{"bar", "baz"},
+		},
+		{
+			desc:     "slice without start",
+			input:    [3]int{1, 2, 3},
+			ref:      must(pl.NewRef(pl.Slice{End: addr(2)})),
+			expected: []int{1, 2},
+		},
+		{
+			desc:     "slice with step",
+			input:    []int{1, 2, 3, 4, 5},
+			ref:      must(pl.NewRef(pl.Slice{Step: addr(2)})),
+			expected: []int{1, 3, 5},
+		},
+		{
+			desc:     "slice with negative step",
+			input:    []int{1, 2, 3, 4, 5},
+			ref:      must(pl.NewRef(pl.Slice{Start: addr(-2), Step: addr(-2)})),
+			expected: []int{4, 2},
+		},
+		{
+			desc:     "slice with bounds out of range",
+			input:    []int{1, 2, 3},
+			ref:      must(pl.NewRef(pl.Slice{Start: addr(-5), End: addr(5)})),
+			expected: []int{1, 2, 3},
+		},
+		{
+			desc:     "empty slice",
+			input:    []int{1, 2, 3},
+			ref:      must(pl.NewRef(pl.Slice{Start: addr(2), End: addr(1)})),
+			expected: []int{},
+		},
+		{
+			desc:     "slice of string",
+			input:    map[string]string{"a": "Rick and Morty"},
+			ref:      must(pl.NewRef("a", pl.Slice{Start: addr(-5)})),
+			expected: "Morty",
+		},
+		{
+			desc:     "wildcard over slice",
+			input:    []map[string]int{{"a": 1}, {"a": 2}},
+			ref:      pl.Ref{{Wildcard: true}, {Name: addr("a")}},
+			expected: []any{1, 2},
+		},
+		{
+			desc:     "wildcard over map in order of keys",
+			input:    map[string]any{"b": 2, "c": 3, "a": 1},
+			ref:      pl.Ref{{Wildcard: true}},
+			expected: []any{1, 2, 3},
+		},
+		{
+			desc:     "wildcard over map of int key",
+			input:    map[int]string{10: "b", -1: "a", 2: "c"},
+			ref:      pl.Ref{{Wildcard: true}},
+			expected: []any{"a", "c", "b"},
+		},
+		{
+			desc:     "wildcard over exported fields of struct",
+			input:    struct{ A, b, C int }{A: 1, b: 2, C: 3},
+			ref:      pl.Ref{{Wildcard: true}},
+			expected: []any{1, 3},
+		},
+		{
+			desc:     "nested wildcards",
+			input:    map[string]any{"a": [][]int{{1, 2}, {3}}},
+			ref:      pl.Ref{{Name: addr("a")}, {Wildcard: true}, {Wildcard: true}},
+			expected: []any{1, 2, 3},
+		},
+		{
+			desc:     "wildcard over empty list",
+			input:    []int{},
+			ref:      pl.Ref{{Wildcard: true}},
+			expected: []any{},
+		},
+		{
+			desc: "recursive descent",
+			input: map[string]any{
+				"image": "a",
+				"services": map[string]any{
+					"web": map[string]any{"image": "b", "sidecars": []any{map[string]any{"image": "c"}}},
+					"db":  &struct{ Image, image string }{Image: "d", image: "e"},
+				},
+			},
+			ref:      pl.Ref{{Descent: addr("image")}},
+			expected: []any{"a", "b", "c"},
+		},
+		{
+			desc:     "recursive descent into struct",
+			input:    []any{struct{ Image string }{Image: "a"}, map[string]any{"Image": "b"}},
+			ref:      pl.Ref{{Descent: addr("Image")}},
+			expected: []any{"a", "b"},
+		},
+		{
+			desc:     "recursive descent followed by key",
+			input:    map[string]any{"a": map[string]any{"tag": map[string]any{"v": 1}}, "b": map[string]any{"tag": map[string]any{"v": 2}}},
+			ref:      pl.Ref{{Descent: addr("tag")}, {Name: addr("v")}},
+			expected: []any{1, 2},
+		},
+		{
+			desc:     "optional key under wildcard",
+			input:    []map[string]int{{"a": 1}, {}},
+			ref:      pl.Ref{{Wildcard: true}, {Name: addr("a"), Optional: true}},
+			expected: []any{1, nil},
+		},
+		{
+			desc:     "optional key that does not exist",
+			input:    map[string]any{"a": map[string]any{}},
+			ref:      pl.Ref{{Name: addr("a")}, {Name: addr("b"), Optional: true}, {Name: addr("c")}},
+			expected: nil,
+		},
+		{
+			desc:     "optional field that does not exist",
+			input:    struct{ A int }{},
+			ref:      pl.Ref{{Name: addr("B"), Optional: true}},
+			expected: nil,
+		},
+		{
+			desc:     "optional index that is out of range",
+			input:    []string{"foo"},
+			ref:      pl.Ref{{Index: addr(1), Optional: true}},
+			expected: nil,
+		},
+		{
+			desc:     "optional key of nil value",
+			input:    map[string]any{"a": nil},
+			ref:      pl.Ref{{Name: addr("a"), Optional: true}, {Name: addr("b")}},
+			expected: nil,
+		},
+		{
+			desc:     "optional field of nil pointer",
+			input:    struct{ A *struct{ B int } }{},
+			ref:      pl.Ref{{Name: addr("A"), Optional: true}, {Name: addr("B")}},
+			expected: nil,
+		},
+		{
+			desc:     "optional key that exists",
+			input:    map[string]any{"a": map[string]any{"b": 42}},
+			ref:      pl.Ref{{Name: addr("a"), Optional: true}, {Name: addr("b"), Optional: true}},
+			expected: 42,
+		},
 	}
 	for _, tc := range tcs {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -201,6 +385,72 @@ func TestResolve(t *testing.T) {
 				ref:   must(pl.NewRef(3)),
 				msgs:  []string{"out of range"},
 			},
+			{
+				desc:  "nil data",
+				input: nil,
+				ref:   must(pl.NewRef("a")),
+				msgs:  []string{"$ is nil"},
+			},
+			{
+				desc:  "nil value using key",
+				input: map[string]any{"a": map[string]any{"b": nil}},
+				ref:   must(pl.NewRef("a", "b", "c")),
+				msgs:  []string{"$.a.b is nil"},
+			},
+			{
+				desc:  "nil pointer using key",
+				input: struct{ A *struct{ B int } }{},
+				ref:   must(pl.NewRef("A", "B")),
+				msgs:  []string{"$.A is nil"},
+			},
+			{
+				desc:  "negative index out of range",
+				input: []string{"foo"},
+				ref:   must(pl.NewRef(-2)),
+				msgs:  []string{"out of range"},
+			},
+			{
+				desc:  "slice of non-list",
+				input: map[string]int{},
+				ref:   must(pl.NewRef(pl.Slice{})),
+				msgs:  []string{"not", "list"},
+			},
+			{
+				desc:  "slice with zero step",
+				input: []int{1},
+				ref:   must(pl.NewRef(pl.Slice{Step: addr(0)})),
+				msgs:  []string{"[::0]", "step", "zero"},
+			},
+			{
+				desc:  "wildcard over non-list",
+				input: map[string]any{"a": 42},
+				ref:   pl.Ref{{Name: addr("a")}, {Wildcard: true}},
+				msgs:  []string{"$.a", "not a list or an object"},
+			},
+			{
+				desc:  "key under wildcard does not exist",
+				input: []map[string]int{{"a": 1}, {}},
+				ref:   pl.Ref{{Wildcard: true}, {Name: addr("a")}},
+				msgs:  []string{"$[*]", "no", "key", "a"},
+			},
+			{
+				desc:  "filter without executor",
+				input: []int{1},
+				ref:   pl.Ref{{Filter: &pl.Filter{Ref: must(pl.NewRef("a"))}}},
+				msgs:  []string{"$[?(.a)]", "executor"},
+			},
+			{
+				desc:  "optional key of non-object",
+				input: map[string]any{"a": 42},
+				ref:   pl.Ref{{Name: addr("a"), Optional: true}, {Name: addr("b"), Optional: true}},
+				msgs:  []string{"not", "object"},
+			},
+			{
+				desc:  "key following optional key that does not exist",
+				input: map[string]any{"a": map[string]any{}},
+				ref:   pl.Ref{{Name: addr("a"), Optional: true}, {Name: addr("b")}},
+				msgs:  []string{"no", "key", "b"},
+			},
 			{
 				desc:  "invalid key",
 				input: struct{}{},
@@ -220,3 +470,20 @@ func TestResolve(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveAll(t *testing.T) {
+	require := require.New(t)
+
+	data := map[string]any{"a": []int{1, 2}}
+
+	vs, err := pl.ResolveAll(data, must(pl.NewRef("a")))
+	require.NoError(err)
+	require.Equal([]any{[]int{1, 2}}, vs)
+
+	vs, err = pl.ResolveAll(data, pl.Ref{{Name: addr("a")}, {Wildcard: true}})
+	require.NoError(err)
+	require.Equal([]any{1, 2}, vs)
+
+	_, err = pl.ResolveAll(data, must(pl.NewRef("b")))
+	require.ErrorIs(err, pl.ErrRefNotFound)
+}